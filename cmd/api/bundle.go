@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/bundle"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/db"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runExport writes the bundle of the site with the given slug.
+// Usage: export <slug> [file]
+func runExport(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: export <slug> [file]")
+	}
+	slug := args[0]
+	path := slug + ".zip"
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	mongoConn, err := db.Connect(ctx, cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		return fmt.Errorf("mongo error: %w", err)
+	}
+	defer func() { _ = mongoConn.Client.Disconnect(ctx) }()
+
+	var site models.Site
+	if err := mongoConn.DB.Collection("sites").FindOne(ctx, bson.M{"slug": slug}).Decode(&site); err != nil {
+		return fmt.Errorf("find site %q: %w", slug, err)
+	}
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, bundle.FromSite(site, time.Now().UTC())); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %s to %s\n", slug, path)
+	return nil
+}
+
+// runImport creates a draft site from a bundle file.
+// Usage: import [-slug slug] [-owner email] [-on-conflict rename|fail] <file>
+func runImport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	slug := fs.String("slug", "", "slug for the imported site (defaults to the bundle slug)")
+	owner := fs.String("owner", "", "email of the user to make owner")
+	onConflict := fs.String("on-conflict", bundle.OnConflictRename, "rename or fail when the slug is taken")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-slug slug] [-owner email] [-on-conflict rename|fail] <file>")
+	}
+	if *onConflict != bundle.OnConflictRename && *onConflict != bundle.OnConflictFail {
+		return fmt.Errorf("-on-conflict must be rename or fail")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := bundle.Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	mongoConn, err := db.Connect(ctx, cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		return fmt.Errorf("mongo error: %w", err)
+	}
+	defer func() { _ = mongoConn.Client.Disconnect(ctx) }()
+
+	opts := bundle.ImportOptions{Slug: *slug, OnConflict: *onConflict}
+	if *owner != "" {
+		var user models.User
+		email := strings.ToLower(strings.TrimSpace(*owner))
+		if err := mongoConn.DB.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+			return fmt.Errorf("find owner %q: %w", email, err)
+		}
+		opts.OwnerID = user.ID
+	}
+
+	importer := &bundle.Importer{
+		Sites:           mongoConn.DB.Collection("sites"),
+		SitePermissions: mongoConn.DB.Collection("site_permissions"),
+		SlugRedirects:   mongoConn.DB.Collection("slug_redirects"),
+	}
+	site, err := importer.Import(ctx, b, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported site %s as %s\n", site.ID.Hex(), site.Slug)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/jobs"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/mail"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/storage"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/webhooks"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// startJobs launches the background jobs. Every replica starts them; leases
+// make sure only one replica acts at a time.
+func startJobs(ctx context.Context, database *mongo.Database, cfg *config.Config, bus *events.Bus, store storage.Storage) {
+	holder := jobs.NewHolderID()
+	leases := database.Collection("job_leases")
+	interval := time.Duration(cfg.SchedulerInterval) * time.Second
+
+	scheduler := &jobs.PublishScheduler{Sites: database.Collection("sites"), Events: bus}
+	go jobs.RunLeased(ctx, &jobs.Lease{Leases: leases, Name: "publish-scheduler", Holder: holder, TTL: 3 * interval}, interval, scheduler.Tick)
+
+	verifier := &jobs.DomainVerifier{Verifier: &domains.Verifier{Domains: database.Collection("site_domains"), Resolver: net.DefaultResolver, PendingTimeout: domains.DefaultPendingTimeout}}
+	go jobs.RunLeased(ctx, &jobs.Lease{Leases: leases, Name: "domain-verifier", Holder: holder, TTL: 10 * time.Minute}, time.Minute, verifier.Tick)
+
+	purger := &jobs.TrashPurger{DB: database, Storage: store, Retention: time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour}
+	go jobs.RunLeased(ctx, &jobs.Lease{Leases: leases, Name: "trash-purger", Holder: holder, TTL: 10 * time.Minute}, time.Hour, purger.Tick)
+
+	dispatcher := &jobs.WebhookDispatcher{Dispatcher: &webhooks.Dispatcher{
+		Events:     database.Collection("site_events"),
+		Webhooks:   database.Collection("webhooks"),
+		Deliveries: database.Collection("webhook_deliveries"),
+		Client:     webhooks.NewClient(cfg.WebhookPrivateIPs),
+	}}
+	// Every replica queues the events it publishes; sends are claimed one by
+	// one, so a tick outlasting the short lease is safe.
+	bus.OnPublish(dispatcher.Dispatcher.Queue)
+	webhookInterval := 5 * time.Second
+	go jobs.RunLeased(ctx, &jobs.Lease{Leases: leases, Name: "webhook-dispatcher", Holder: holder, TTL: 3 * webhookInterval}, webhookInterval, dispatcher.Tick)
+
+	if cfg.SMTPHost != "" {
+		notifier := &jobs.FormNotifier{Notifier: &forms.Notifier{
+			Submissions: database.Collection("form_submissions"),
+			Forms:       database.Collection("forms"),
+			Sites:       database.Collection("sites"),
+			Mailer:      &mail.SMTP{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.SMTPFrom},
+		}}
+		go jobs.RunLeased(ctx, &jobs.Lease{Leases: leases, Name: "form-notifier", Holder: holder, TTL: 5 * time.Minute}, 15*time.Second, notifier.Tick)
+	}
+}
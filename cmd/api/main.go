@@ -13,6 +13,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/db"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/middleware"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/routes"
 )
 
@@ -23,11 +25,24 @@ func main() {
 	}
 
 	ctx := context.Background()
-	if len(os.Args) > 1 && os.Args[1] == "seed" {
-		if err := runSeed(ctx, cfg); err != nil {
-			log.Fatalf("seed error: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "seed":
+			if err := runSeed(ctx, cfg); err != nil {
+				log.Fatalf("seed error: %v", err)
+			}
+			return
+		case "export":
+			if err := runExport(ctx, cfg, os.Args[2:]); err != nil {
+				log.Fatalf("export error: %v", err)
+			}
+			return
+		case "import":
+			if err := runImport(ctx, cfg, os.Args[2:]); err != nil {
+				log.Fatalf("import error: %v", err)
+			}
+			return
 		}
-		return
 	}
 
 	mongoConn, err := db.Connect(ctx, cfg.MongoURI, cfg.MongoDB)
@@ -41,11 +56,28 @@ func main() {
 	if err := db.EnsureIndexes(ctx, mongoConn.DB); err != nil {
 		log.Fatalf("index error: %v", err)
 	}
+	if err := db.Migrate(ctx, mongoConn.DB); err != nil {
+		log.Fatalf("migration error: %v", err)
+	}
+
+	store, err := newStorage(cfg)
+	if err != nil {
+		log.Fatalf("storage error: %v", err)
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	bus := events.NewBus(mongoConn.DB.Collection("site_events"))
+	go bus.Run(jobsCtx)
+	startJobs(jobsCtx, mongoConn.DB, cfg, bus, store)
 
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(middleware.Logger(), gin.Recovery())
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("trusted proxies error: %v", err)
+	}
 
-	routes.RegisterRoutes(router, mongoConn.DB, cfg)
+	routes.RegisterRoutes(router, mongoConn.DB, cfg, bus, store)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -68,6 +100,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	stopJobs()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/storage"
+)
+
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	if cfg.StorageDriver == "s3" {
+		return storage.NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	}
+	return storage.NewLocal(cfg.StorageLocalDir)
+}
@@ -0,0 +1,152 @@
+// Package bundle reads and writes site export bundles: zip archives holding a
+// manifest and the site's metadata, content and settings.
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/locales"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+)
+
+const (
+	Format  = "youpp-site-bundle"
+	Version = 1
+
+	manifestFile = "manifest.json"
+	siteFile     = "site.json"
+
+	// maxEntrySize bounds a single decompressed archive entry.
+	maxEntrySize = 32 << 20
+)
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type Manifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	SourceID   string    `json:"sourceId,omitempty"`
+}
+
+type Settings struct {
+	WorkflowEnabled bool                 `json:"workflowEnabled"`
+	Locales         *models.SiteLocales  `json:"locales,omitempty"`
+	General         *models.SiteSettings `json:"general,omitempty"`
+}
+
+type Site struct {
+	Name             string                 `json:"name"`
+	Slug             string                 `json:"slug"`
+	Content          map[string]interface{} `json:"content"`
+	PublishedContent map[string]interface{} `json:"publishedContent,omitempty"`
+	Settings         Settings               `json:"settings"`
+}
+
+type Bundle struct {
+	Manifest Manifest
+	Site     Site
+}
+
+// FromSite builds a bundle for site. Workflow approvers, history and other
+// environment-specific references are left out.
+func FromSite(site models.Site, now time.Time) *Bundle {
+	b := &Bundle{
+		Manifest: Manifest{Format: Format, Version: Version, ExportedAt: now, SourceID: site.ID.Hex()},
+		Site: Site{
+			Name:             site.Name,
+			Slug:             site.Slug,
+			Content:          site.Content,
+			PublishedContent: site.PublishedContent,
+			Settings:         Settings{Locales: site.Locales, General: site.Settings},
+		},
+	}
+	if site.Workflow != nil {
+		b.Site.Settings.WorkflowEnabled = site.Workflow.Enabled
+	}
+	if b.Site.Content == nil {
+		b.Site.Content = map[string]interface{}{}
+	}
+	return b
+}
+
+// Write encodes b as a zip archive.
+func Write(w io.Writer, b *Bundle) error {
+	zw := zip.NewWriter(w)
+	if err := writeJSON(zw, manifestFile, b.Manifest); err != nil {
+		return err
+	}
+	if err := writeJSON(zw, siteFile, b.Site); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Read decodes a zip archive written by Write. Bundles from a newer format
+// version are rejected.
+func Read(r io.ReaderAt, size int64) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var b Bundle
+	if err := readJSON(files, manifestFile, &b.Manifest); err != nil {
+		return nil, err
+	}
+	if b.Manifest.Format != Format {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidBundle, b.Manifest.Format)
+	}
+	if b.Manifest.Version < 1 || b.Manifest.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, b.Manifest.Version)
+	}
+	if err := readJSON(files, siteFile, &b.Site); err != nil {
+		return nil, err
+	}
+	if b.Site.Content == nil {
+		b.Site.Content = map[string]interface{}{}
+	}
+	if l := b.Site.Settings.Locales; l != nil {
+		for _, tag := range append([]string{l.Default}, l.Additional...) {
+			if !locales.Valid(tag) {
+				return nil, fmt.Errorf("%w: invalid locale %q", ErrInvalidBundle, tag)
+			}
+		}
+	}
+	return &b, nil
+}
+
+func readJSON(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidBundle, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(io.LimitReader(rc, maxEntrySize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidBundle, name, err)
+	}
+	return nil
+}
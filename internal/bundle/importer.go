@@ -0,0 +1,144 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/settings"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/slugs"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// OnConflictRename picks the first free suffixed slug.
+	OnConflictRename = "rename"
+	// OnConflictFail rejects the import when the slug is taken.
+	OnConflictFail = "fail"
+)
+
+var (
+	ErrInvalidSlug  = errors.New("invalid slug")
+	ErrSlugConflict = errors.New("slug already exists")
+)
+
+// ContentError reports bundle content or settings that fail validation.
+type ContentError struct {
+	Errors []sections.ValidationError
+}
+
+func (e *ContentError) Error() string {
+	return fmt.Sprintf("invalid content: %d errors", len(e.Errors))
+}
+
+type Importer struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	SlugRedirects   *mongo.Collection
+}
+
+type ImportOptions struct {
+	// Slug overrides the slug stored in the bundle.
+	Slug       string
+	OnConflict string
+	OwnerID    primitive.ObjectID
+}
+
+// Import creates a new draft site from b. Published content is carried over
+// as the last published version, but the site stays offline until it is
+// published in its new environment.
+func (im *Importer) Import(ctx context.Context, b *Bundle, opts ImportOptions) (*models.Site, error) {
+	b.Site.Content = pages.Normalize(b.Site.Content)
+	// Sites that were never edited export an empty draft.
+	if len(b.Site.Content) == 0 {
+		b.Site.Content = map[string]interface{}{"pages": []interface{}{}}
+	}
+	b.Site.PublishedContent = pages.Normalize(b.Site.PublishedContent)
+	if errs := pages.ValidateContent(b.Site.Content); len(errs) > 0 {
+		return nil, &ContentError{Errors: errs}
+	}
+	// The snapshot is served as soon as the site is published, so it has to
+	// pass the same checks as the draft.
+	if b.Site.PublishedContent != nil {
+		if errs := pages.ValidateContent(b.Site.PublishedContent); len(errs) > 0 {
+			for i := range errs {
+				errs[i].Path = "/publishedContent" + errs[i].Path
+			}
+			return nil, &ContentError{Errors: errs}
+		}
+	}
+	if general := b.Site.Settings.General; general != nil {
+		normalized := settings.Normalize(*general)
+		if errs := settings.Validate(normalized); len(errs) > 0 {
+			for i := range errs {
+				errs[i].Path = "/settings/general" + errs[i].Path
+			}
+			return nil, &ContentError{Errors: errs}
+		}
+		b.Site.Settings.General = &normalized
+	}
+
+	slug := opts.Slug
+	if slug == "" {
+		slug = b.Site.Slug
+	}
+	slug = utils.NormalizeSlug(slug)
+	if !utils.IsValidSlug(slug) {
+		return nil, ErrInvalidSlug
+	}
+	available, err := slugs.Available(ctx, im.Sites, im.SlugRedirects, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		if opts.OnConflict == OnConflictFail {
+			return nil, ErrSlugConflict
+		}
+		if slug, err = slugs.ResolveUnique(ctx, im.Sites, im.SlugRedirects, slug); err != nil {
+			return nil, err
+		}
+	}
+
+	name := b.Site.Name
+	if name == "" {
+		name = slug
+	}
+	now := time.Now().UTC()
+	site := models.Site{
+		Name:                  name,
+		Slug:                  slug,
+		Status:                "draft",
+		Content:               b.Site.Content,
+		PublishedContent:      b.Site.PublishedContent,
+		HasUnpublishedChanges: true,
+		Locales:               b.Site.Settings.Locales,
+		Settings:              b.Site.Settings.General,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	if b.Site.Settings.WorkflowEnabled {
+		site.Workflow = &models.SiteWorkflow{Enabled: true}
+	}
+	result, err := im.Sites.InsertOne(ctx, site)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrSlugConflict
+		}
+		return nil, err
+	}
+	site.ID = result.InsertedID.(primitive.ObjectID)
+
+	if !opts.OwnerID.IsZero() {
+		permission := models.SitePermission{SiteID: site.ID, UserID: opts.OwnerID, Role: "owner", CreatedAt: now, UpdatedAt: now}
+		if _, err := im.SitePermissions.InsertOne(ctx, permission); err != nil {
+			return nil, fmt.Errorf("grant owner: %w", err)
+		}
+	}
+	return &site, nil
+}
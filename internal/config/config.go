@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -14,8 +15,31 @@ type Config struct {
 	JWTRefreshSecret   string
 	ProvisionAPIKey    string
 	FrontendOrigins    []string
+	PlatformDomains    []string
+	TrustedProxies     []string
 	AccessTTLMinutes   int
 	RefreshTTLDays     int
+	SchedulerInterval  int
+	TrashRetentionDays int
+	PublicBaseURL      string
+	StorageDriver      string
+	StorageLocalDir    string
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKey        string
+	S3SecretKey        string
+	AssetMaxUploadMB   int
+	ImageCacheDir      string
+	PublicCacheControl string
+	PublicCacheEntries int
+	PublicCacheTTLSec  int
+	WebhookPrivateIPs  bool
+	SMTPHost           string
+	SMTPPort           int
+	SMTPUsername       string
+	SMTPPassword       string
+	SMTPFrom           string
 	SuperAdminEmail    string
 	SuperAdminPassword string
 	DemoEmail          string
@@ -31,11 +55,27 @@ func Load() (*Config, error) {
 		JWTRefreshSecret:   os.Getenv("JWT_REFRESH_SECRET"),
 		ProvisionAPIKey:    os.Getenv("PROVISION_API_KEY"),
 		FrontendOrigins:    getFrontendOrigins(),
+		PlatformDomains:    getPlatformDomains(),
 		SuperAdminEmail:    os.Getenv("SUPERADMIN_EMAIL"),
 		SuperAdminPassword: os.Getenv("SUPERADMIN_PASSWORD"),
 		DemoEmail:          os.Getenv("DEMO_EMAIL"),
 		DemoPassword:       os.Getenv("DEMO_PASSWORD"),
 		DemoSiteSlug:       os.Getenv("DEMO_SITE_SLUG"),
+		PublicBaseURL:      strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		StorageDriver:      os.Getenv("STORAGE_DRIVER"),
+		StorageLocalDir:    os.Getenv("STORAGE_LOCAL_DIR"),
+		S3Endpoint:         os.Getenv("S3_ENDPOINT"),
+		S3Region:           os.Getenv("S3_REGION"),
+		S3Bucket:           os.Getenv("S3_BUCKET"),
+		S3AccessKey:        os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:        os.Getenv("S3_SECRET_ACCESS_KEY"),
+		ImageCacheDir:      os.Getenv("IMAGE_CACHE_DIR"),
+		PublicCacheControl: os.Getenv("PUBLIC_CACHE_CONTROL"),
+		WebhookPrivateIPs:  os.Getenv("WEBHOOK_ALLOW_PRIVATE_IPS") == "true",
+		SMTPHost:           os.Getenv("SMTP_HOST"),
+		SMTPUsername:       os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:       os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:           os.Getenv("SMTP_FROM"),
 	}
 
 	if cfg.MongoURI == "" || cfg.MongoDB == "" || cfg.JWTSecret == "" || cfg.JWTRefreshSecret == "" {
@@ -44,6 +84,21 @@ func Load() (*Config, error) {
 	if cfg.DemoSiteSlug == "" {
 		cfg.DemoSiteSlug = "demo-site"
 	}
+	if cfg.StorageDriver == "" {
+		cfg.StorageDriver = "local"
+	}
+	if cfg.StorageDriver != "local" && cfg.StorageDriver != "s3" {
+		return nil, fmt.Errorf("STORAGE_DRIVER must be local or s3")
+	}
+	if cfg.StorageLocalDir == "" {
+		cfg.StorageLocalDir = "data/assets"
+	}
+	if cfg.ImageCacheDir == "" {
+		cfg.ImageCacheDir = "data/image-cache"
+	}
+	if cfg.PublicCacheControl == "" {
+		cfg.PublicCacheControl = "public, max-age=60"
+	}
 
 	accessTTL, err := getEnvInt("ACCESS_TTL_MIN", 15)
 	if err != nil {
@@ -53,8 +108,61 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("REFRESH_TTL_DAYS: %w", err)
 	}
+	schedulerInterval, err := getEnvInt("SCHEDULER_INTERVAL_SEC", 30)
+	if err != nil {
+		return nil, fmt.Errorf("SCHEDULER_INTERVAL_SEC: %w", err)
+	}
+	if schedulerInterval <= 0 {
+		return nil, fmt.Errorf("SCHEDULER_INTERVAL_SEC must be positive")
+	}
+	trashRetention, err := getEnvInt("SITE_TRASH_RETENTION_DAYS", 30)
+	if err != nil {
+		return nil, fmt.Errorf("SITE_TRASH_RETENTION_DAYS: %w", err)
+	}
+	if trashRetention < 0 {
+		return nil, fmt.Errorf("SITE_TRASH_RETENTION_DAYS must not be negative")
+	}
+	maxUpload, err := getEnvInt("ASSET_MAX_UPLOAD_MB", 10)
+	if err != nil {
+		return nil, fmt.Errorf("ASSET_MAX_UPLOAD_MB: %w", err)
+	}
+	if maxUpload <= 0 {
+		return nil, fmt.Errorf("ASSET_MAX_UPLOAD_MB must be positive")
+	}
+	cacheEntries, err := getEnvInt("PUBLIC_CACHE_ENTRIES", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("PUBLIC_CACHE_ENTRIES: %w", err)
+	}
+	if cacheEntries < 0 {
+		return nil, fmt.Errorf("PUBLIC_CACHE_ENTRIES must not be negative")
+	}
+	cacheTTL, err := getEnvInt("PUBLIC_CACHE_TTL_SEC", 300)
+	if err != nil {
+		return nil, fmt.Errorf("PUBLIC_CACHE_TTL_SEC: %w", err)
+	}
+	if cacheTTL <= 0 {
+		return nil, fmt.Errorf("PUBLIC_CACHE_TTL_SEC must be positive")
+	}
+	smtpPort, err := getEnvInt("SMTP_PORT", 587)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP_PORT: %w", err)
+	}
+	trustedProxies, err := getTrustedProxies()
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required with SMTP_HOST")
+	}
 	cfg.AccessTTLMinutes = accessTTL
 	cfg.RefreshTTLDays = refreshTTL
+	cfg.SchedulerInterval = schedulerInterval
+	cfg.TrashRetentionDays = trashRetention
+	cfg.AssetMaxUploadMB = maxUpload
+	cfg.PublicCacheEntries = cacheEntries
+	cfg.PublicCacheTTLSec = cacheTTL
+	cfg.SMTPPort = smtpPort
+	cfg.TrustedProxies = trustedProxies
 
 	return cfg, nil
 }
@@ -81,6 +189,39 @@ func getFrontendOrigins() []string {
 	return origins
 }
 
+func getPlatformDomains() []string {
+	value := os.Getenv("PLATFORM_DOMAINS")
+	if value == "" {
+		return []string{"youpp.com.tr"}
+	}
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		trimmed := strings.ToLower(strings.TrimSpace(domain))
+		if trimmed != "" {
+			domains = append(domains, trimmed)
+		}
+	}
+	return domains
+}
+
+// getTrustedProxies returns the addresses and CIDR ranges of the load
+// balancers whose X-Forwarded-For headers are believed. Without any, client
+// IPs are taken from the connection.
+func getTrustedProxies() ([]string, error) {
+	var proxies []string
+	for _, value := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		trimmed := strings.TrimSpace(value)
+		if trimmed == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(trimmed); err != nil && net.ParseIP(trimmed) == nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", trimmed)
+		}
+		proxies = append(proxies, trimmed)
+	}
+	return proxies, nil
+}
+
 func getEnvInt(key string, fallback int) (int, error) {
 	value := os.Getenv(key)
 	if value == "" {
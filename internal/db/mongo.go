@@ -6,6 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/webhooks"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -43,6 +46,18 @@ func EnsureIndexes(ctx context.Context, database *mongo.Database) error {
 	}); err != nil {
 		return fmt.Errorf("create sites slug index: %w", err)
 	}
+	if _, err := sites.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "publishAt", Value: 1}}, Options: options.Index().SetSparse(true).SetName("publishAt_1")},
+		{Keys: bson.D{{Key: "unpublishAt", Value: 1}}, Options: options.Index().SetSparse(true).SetName("unpublishAt_1")},
+	}); err != nil {
+		return fmt.Errorf("create sites schedule indexes: %w", err)
+	}
+	if _, err := sites.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetSparse(true).SetName("deletedAt_1"),
+	}); err != nil {
+		return fmt.Errorf("create sites deletedAt index: %w", err)
+	}
 
 	users := database.Collection("users")
 	if _, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{
@@ -61,9 +76,243 @@ func EnsureIndexes(ctx context.Context, database *mongo.Database) error {
 		return fmt.Errorf("create site_permissions indexes: %w", err)
 	}
 
+	previews := database.Collection("preview_tokens")
+	if _, err := previews.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true).SetName("tokenHash_1")},
+		{Keys: bson.D{{Key: "siteId", Value: 1}}, Options: options.Index().SetName("siteId_1")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0).SetName("expiresAt_ttl")},
+	}); err != nil {
+		return fmt.Errorf("create preview_tokens indexes: %w", err)
+	}
+
+	reviews := database.Collection("site_reviews")
+	if _, err := reviews.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "createdAt", Value: -1}},
+		Options: options.Index().SetName("siteId_1_createdAt_-1"),
+	}); err != nil {
+		return fmt.Errorf("create site_reviews index: %w", err)
+	}
+
+	threads := database.Collection("comment_threads")
+	if _, err := threads.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "resolved", Value: 1}, {Key: "updatedAt", Value: -1}},
+		Options: options.Index().SetName("siteId_1_resolved_1_updatedAt_-1"),
+	}); err != nil {
+		return fmt.Errorf("create comment_threads index: %w", err)
+	}
+
+	siteEvents := database.Collection("site_events")
+	if _, err := siteEvents.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(events.Retention.Seconds())).SetName("createdAt_ttl"),
+	}); err != nil {
+		return fmt.Errorf("create site_events index: %w", err)
+	}
+	if _, err := siteEvents.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "type", Value: 1}, {Key: "webhooksQueued", Value: 1}, {Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName("type_1_webhooksQueued_1_createdAt_1"),
+	}); err != nil {
+		return fmt.Errorf("create site_events index: %w", err)
+	}
+
+	presence := database.Collection("site_presence")
+	if _, err := presence.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "siteId", Value: 1}}, Options: options.Index().SetName("siteId_1")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0).SetName("expiresAt_ttl")},
+	}); err != nil {
+		return fmt.Errorf("create site_presence indexes: %w", err)
+	}
+
+	if _, err := database.Collection("stream_tickets").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("expiresAt_ttl"),
+	}); err != nil {
+		return fmt.Errorf("create stream_tickets index: %w", err)
+	}
+
+	locks := database.Collection("site_locks")
+	if _, err := locks.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "siteId", Value: 1}, {Key: "section", Value: 1}}, Options: options.Index().SetUnique(true).SetName("siteId_1_section_1")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0).SetName("expiresAt_ttl")},
+	}); err != nil {
+		return fmt.Errorf("create site_locks indexes: %w", err)
+	}
+
+	redirects := database.Collection("slug_redirects")
+	if _, err := redirects.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true).SetName("slug_1")},
+		{Keys: bson.D{{Key: "siteId", Value: 1}}, Options: options.Index().SetName("siteId_1")},
+	}); err != nil {
+		return fmt.Errorf("create slug_redirects indexes: %w", err)
+	}
+
+	templates := database.Collection("templates")
+	if _, err := templates.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "category", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetName("category_1_name_1"),
+	}); err != nil {
+		return fmt.Errorf("create templates index: %w", err)
+	}
+
+	assets := database.Collection("assets")
+	if _, err := assets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "createdAt", Value: -1}},
+		Options: options.Index().SetName("siteId_1_createdAt_-1"),
+	}); err != nil {
+		return fmt.Errorf("create assets index: %w", err)
+	}
+
+	siteDomains := database.Collection("site_domains")
+	if _, err := siteDomains.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// A hostname can be claimed by several sites but verified by one.
+			Keys: bson.D{{Key: "hostname", Value: 1}},
+			Options: options.Index().
+				SetName("hostname_1_verified").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": "verified"}),
+		},
+		{
+			Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "hostname", Value: 1}},
+			Options: options.Index().SetName("siteId_1_hostname_1").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "lastCheckedAt", Value: 1}},
+			Options: options.Index().SetName("status_1_lastCheckedAt_1"),
+		},
+	}); err != nil {
+		return fmt.Errorf("create site_domains indexes: %w", err)
+	}
+
+	if _, err := database.Collection("webhooks").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName("siteId_1_createdAt_1"),
+	}); err != nil {
+		return fmt.Errorf("create webhooks index: %w", err)
+	}
+
+	deliveries := database.Collection("webhook_deliveries")
+	if _, err := deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// Queuing an event twice must not deliver it twice.
+			Keys:    bson.D{{Key: "webhookId", Value: 1}, {Key: "eventId", Value: 1}},
+			Options: options.Index().SetName("webhookId_1_eventId_1").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("webhookId_1_createdAt_-1"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+			Options: options.Index().SetName("status_1_nextAttemptAt_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(webhooks.Retention.Seconds())).SetName("createdAt_ttl"),
+		},
+	}); err != nil {
+		return fmt.Errorf("create webhook_deliveries indexes: %w", err)
+	}
+
+	if _, err := database.Collection("forms").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName("siteId_1_createdAt_1"),
+	}); err != nil {
+		return fmt.Errorf("create forms index: %w", err)
+	}
+
+	if _, err := database.Collection("form_submissions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("siteId_1__id_-1"),
+		},
+		{
+			Keys:    bson.D{{Key: "formId", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("formId_1__id_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "notification.status", Value: 1}, {Key: "notification.nextAttemptAt", Value: 1}},
+			Options: options.Index().SetName("notification.status_1_notification.nextAttemptAt_1"),
+		},
+	}); err != nil {
+		return fmt.Errorf("create form_submissions indexes: %w", err)
+	}
+
+	if _, err := database.Collection("form_rate_limits").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("expiresAt_ttl"),
+	}); err != nil {
+		return fmt.Errorf("create form_rate_limits index: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate applies idempotent data migrations required by the current models.
+func Migrate(ctx context.Context, database *mongo.Database) error {
+	sites := database.Collection("sites")
+	// Sites published before draft/published snapshots existed serve their live content.
+	if _, err := sites.UpdateMany(ctx,
+		bson.M{"status": "published", "publishedContent": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"publishedContent": "$content", "hasUnpublishedChanges": false}}}},
+	); err != nil {
+		return fmt.Errorf("migrate published content snapshots: %w", err)
+	}
+	// Sites created before optimistic concurrency start at version 0, so
+	// If-Match and version-guarded updates can match them.
+	if _, err := sites.UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": int64(0)}},
+	); err != nil {
+		return fmt.Errorf("migrate site versions: %w", err)
+	}
+	// Single-page content becomes the home page of a multi-page site.
+	for _, target := range []struct {
+		coll  *mongo.Collection
+		field string
+	}{
+		{sites, "content"},
+		{sites, "publishedContent"},
+		{database.Collection("templates"), "content"},
+	} {
+		if err := migrateSinglePage(ctx, target.coll, target.field); err != nil {
+			return fmt.Errorf("migrate %s.%s to pages: %w", target.coll.Name(), target.field, err)
+		}
+	}
+	// Comment anchors point into the sections of what is now the home page.
+	if _, err := database.Collection("comment_threads").UpdateMany(ctx,
+		bson.M{"anchor.path": bson.M{"$regex": "^/sections/"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"anchor.path": bson.M{"$concat": bson.A{"/pages/0", "$anchor.path"}}}}}},
+	); err != nil {
+		return fmt.Errorf("migrate comment anchors to pages: %w", err)
+	}
 	return nil
 }
 
+func migrateSinglePage(ctx context.Context, coll *mongo.Collection, field string) error {
+	cursor, err := coll.Find(ctx,
+		bson.M{field + ".sections": bson.M{"$exists": true}, field + ".pages": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{field: 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var content map[string]interface{}
+		if err := cursor.Current.Lookup(field).Unmarshal(&content); err != nil {
+			return err
+		}
+		if _, err := coll.UpdateOne(ctx,
+			bson.M{"_id": cursor.Current.Lookup("_id"), field + ".pages": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: pages.Normalize(content)}},
+		); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
 func validateDuplicateSlugs(ctx context.Context, sites *mongo.Collection) error {
 	pipeline := mongo.Pipeline{
 		{{Key: "$group", Value: bson.M{"_id": "$slug", "count": bson.M{"$sum": 1}}}},
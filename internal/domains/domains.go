@@ -0,0 +1,140 @@
+// Package domains verifies ownership of custom site hostnames through DNS
+// TXT records.
+package domains
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusFailed   = "failed"
+
+	recordPrefix = "_youpp-verify."
+	valuePrefix  = "youpp-verify="
+
+	// DefaultPendingTimeout is how long a new domain may stay unverified
+	// before it is marked failed.
+	DefaultPendingTimeout = 72 * time.Hour
+)
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Resolver looks up DNS TXT records. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// RecordName is the TXT record name the owner has to create.
+func RecordName(hostname string) string {
+	return recordPrefix + hostname
+}
+
+// RecordValue is the TXT record value proving ownership.
+func RecordValue(token string) string {
+	return valuePrefix + token
+}
+
+// NormalizeHostname lowercases raw and strips a port and trailing dot. It
+// reports false for anything that is not a plain multi-label hostname.
+func NormalizeHostname(raw string) (string, bool) {
+	host := strings.ToLower(strings.TrimSpace(raw))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 || len(host) > 253 || net.ParseIP(host) != nil {
+		return "", false
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if !labelPattern.MatchString(label) {
+			return "", false
+		}
+	}
+	return host, true
+}
+
+// Verifier checks domains and records the outcome.
+type Verifier struct {
+	Domains        *mongo.Collection
+	Resolver       Resolver
+	PendingTimeout time.Duration
+}
+
+// Verify looks up the TXT record of d and updates its status in place and in
+// the database. DNS failures other than a missing record leave the status
+// alone, so a resolver hiccup never takes a verified domain offline.
+func (v *Verifier) Verify(ctx context.Context, d *models.SiteDomain) error {
+	now := time.Now().UTC()
+	records, err := v.Resolver.LookupTXT(ctx, RecordName(d.Hostname))
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		d.LastError = "dns lookup failed: " + err.Error()
+		d.LastCheckedAt = &now
+		_, err := v.Domains.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{"$set": bson.M{"lastError": d.LastError, "lastCheckedAt": now}})
+		return err
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == RecordValue(d.VerificationToken) {
+			found = true
+			break
+		}
+	}
+
+	set := bson.M{"lastCheckedAt": now, "updatedAt": now}
+	unset := bson.M{}
+	switch {
+	case found:
+		if d.Status != StatusVerified {
+			set["verifiedAt"] = now
+			d.VerifiedAt = &now
+		}
+		d.Status = StatusVerified
+		d.LastError = ""
+		unset["lastError"] = ""
+	case d.Status == StatusVerified || now.Sub(d.CreatedAt) > v.PendingTimeout:
+		d.Status = StatusFailed
+		d.LastError = "verification record not found"
+	default:
+		d.LastError = "verification record not found"
+	}
+	set["status"] = d.Status
+	if d.LastError != "" {
+		set["lastError"] = d.LastError
+	}
+	d.LastCheckedAt = &now
+	d.UpdatedAt = now
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err = v.Domains.UpdateOne(ctx, bson.M{"_id": d.ID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another site already verified this hostname.
+		d.Status = StatusFailed
+		d.LastError = "hostname is verified for another site"
+		d.VerifiedAt = nil
+		_, err = v.Domains.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{
+			"$set":   bson.M{"status": d.Status, "lastError": d.LastError, "lastCheckedAt": now, "updatedAt": now},
+			"$unset": bson.M{"verifiedAt": ""},
+		})
+	}
+	return err
+}
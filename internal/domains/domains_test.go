@@ -0,0 +1,171 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type fakeResolver struct {
+	records []string
+	err     error
+	asked   []string
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	r.asked = append(r.asked, name)
+	return r.records, r.err
+}
+
+var (
+	errNoRecord  = &net.DNSError{Err: "no such host", Name: "_youpp-verify.example.com", IsNotFound: true}
+	errTransient = &net.DNSError{Err: "i/o timeout", Name: "_youpp-verify.example.com", IsTimeout: true}
+)
+
+func testDomain(status string, age time.Duration) *models.SiteDomain {
+	now := time.Now().UTC()
+	d := &models.SiteDomain{
+		ID:                primitive.NewObjectID(),
+		SiteID:            primitive.NewObjectID(),
+		Hostname:          "example.com",
+		VerificationToken: "tok3n",
+		Status:            status,
+		CreatedAt:         now.Add(-age),
+		UpdatedAt:         now.Add(-age),
+	}
+	if status == StatusVerified {
+		verifiedAt := now.Add(-age)
+		d.VerifiedAt = &verifiedAt
+	}
+	return d
+}
+
+// sentUpdate returns the update document of the single UpdateOne sent.
+func sentUpdate(mt *mtest.T) bson.Raw {
+	mt.Helper()
+	started := mt.GetStartedEvent()
+	if started == nil || started.CommandName != "update" {
+		mt.Fatalf("no update sent, got %v", started)
+	}
+	return started.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+}
+
+func TestVerify(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	updated := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+	tests := []struct {
+		name       string
+		domain     *models.SiteDomain
+		records    []string
+		err        error
+		wantStatus string
+		wantError  string
+	}{
+		{
+			name:       "pending becomes verified",
+			domain:     testDomain(StatusPending, time.Hour),
+			records:    []string{"other=1", "  youpp-verify=tok3n "},
+			wantStatus: StatusVerified,
+		},
+		{
+			name:       "pending stays pending without record",
+			domain:     testDomain(StatusPending, time.Hour),
+			err:        errNoRecord,
+			wantStatus: StatusPending,
+			wantError:  "verification record not found",
+		},
+		{
+			name:       "pending stays pending with wrong record",
+			domain:     testDomain(StatusPending, time.Hour),
+			records:    []string{"youpp-verify=other"},
+			wantStatus: StatusPending,
+			wantError:  "verification record not found",
+		},
+		{
+			name:       "pending fails after timeout",
+			domain:     testDomain(StatusPending, DefaultPendingTimeout+time.Minute),
+			err:        errNoRecord,
+			wantStatus: StatusFailed,
+			wantError:  "verification record not found",
+		},
+		{
+			name:       "verified fails when record disappears",
+			domain:     testDomain(StatusVerified, 30*24*time.Hour),
+			err:        errNoRecord,
+			wantStatus: StatusFailed,
+			wantError:  "verification record not found",
+		},
+		{
+			name:       "verified stays verified on transient error",
+			domain:     testDomain(StatusVerified, time.Hour),
+			err:        errTransient,
+			wantStatus: StatusVerified,
+			wantError:  "dns lookup failed: " + errTransient.Error(),
+		},
+		{
+			name:       "pending stays pending on transient error after timeout",
+			domain:     testDomain(StatusPending, DefaultPendingTimeout+time.Minute),
+			err:        errors.New("resolver unavailable"),
+			wantStatus: StatusPending,
+			wantError:  "dns lookup failed: resolver unavailable",
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(updated)
+			resolver := &fakeResolver{records: tt.records, err: tt.err}
+			v := &Verifier{Domains: mt.Coll, Resolver: resolver, PendingTimeout: DefaultPendingTimeout}
+			before := tt.domain.Status
+
+			if err := v.Verify(context.Background(), tt.domain); err != nil {
+				mt.Fatalf("Verify: %v", err)
+			}
+			if len(resolver.asked) != 1 || resolver.asked[0] != "_youpp-verify.example.com" {
+				mt.Fatalf("looked up %v, want the verification record", resolver.asked)
+			}
+			if tt.domain.Status != tt.wantStatus || tt.domain.LastError != tt.wantError {
+				mt.Fatalf("got %q %q, want %q %q", tt.domain.Status, tt.domain.LastError, tt.wantStatus, tt.wantError)
+			}
+			if tt.domain.LastCheckedAt == nil {
+				mt.Fatal("lastCheckedAt not set")
+			}
+
+			set := sentUpdate(mt).Lookup("$set").Document()
+			status, hasStatus := set.Lookup("status").StringValueOK()
+			transient := tt.err != nil && !errors.Is(tt.err, errNoRecord)
+			switch {
+			case transient && hasStatus:
+				mt.Fatalf("transient error wrote status %q", status)
+			case !transient && status != tt.wantStatus:
+				mt.Fatalf("stored status %q, want %q", status, tt.wantStatus)
+			}
+			_, verifiedAtSet := set.Lookup("verifiedAt").TimeOK()
+			if wantVerifiedAt := tt.wantStatus == StatusVerified && before != StatusVerified; verifiedAtSet != wantVerifiedAt {
+				mt.Fatalf("verifiedAt written = %v, want %v", verifiedAtSet, wantVerifiedAt)
+			}
+		})
+	}
+
+	mt.Run("hostname verified for another site", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "E11000 duplicate key error"}),
+			updated,
+		)
+		d := testDomain(StatusPending, time.Hour)
+		v := &Verifier{Domains: mt.Coll, Resolver: &fakeResolver{records: []string{"youpp-verify=tok3n"}}, PendingTimeout: DefaultPendingTimeout}
+		if err := v.Verify(context.Background(), d); err != nil {
+			mt.Fatalf("Verify: %v", err)
+		}
+		if d.Status != StatusFailed || d.VerifiedAt != nil || d.LastError != "hostname is verified for another site" {
+			mt.Fatalf("got %q %q verifiedAt %v", d.Status, d.LastError, d.VerifiedAt)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// Package events distributes site events between API replicas. Events are
+// written to Mongo and every replica polls for new ones, fanning them out to
+// its local subscribers.
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ContentUpdated  = "content.updated"
+	SitePublished   = "site.published"
+	SiteUnpublished = "site.unpublished"
+	MemberAdded     = "member.added"
+	LocksChanged    = "locks.changed"
+	SettingsUpdated = "settings.updated"
+	SiteUpdated     = "site.updated"
+	Presence        = "presence"
+)
+
+// Retention is how long events stay in the collection.
+const Retention = 24 * time.Hour
+
+const (
+	pollInterval = 500 * time.Millisecond
+	// Events from other replicas can become visible slightly out of order,
+	// so each poll looks back this far and skips events it already delivered.
+	pollOverlap      = 5 * time.Second
+	subscriberBuffer = 32
+)
+
+type Event struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	SiteID    primitive.ObjectID     `bson:"siteId" json:"siteId"`
+	Type      string                 `bson:"type" json:"type"`
+	Data      map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
+}
+
+type Bus struct {
+	events *mongo.Collection
+
+	mu          sync.Mutex
+	subscribers map[primitive.ObjectID]map[chan Event]struct{}
+	listeners   []func(Event)
+	hooks       []func(context.Context, Event) error
+}
+
+func NewBus(events *mongo.Collection) *Bus {
+	return &Bus{events: events, subscribers: map[primitive.ObjectID]map[chan Event]struct{}{}}
+}
+
+// Publish records an event for every replica. A nil bus drops the event, and
+// failures are logged rather than returned: events are advisory and must not
+// fail the write that caused them.
+func (b *Bus) Publish(ctx context.Context, siteID primitive.ObjectID, eventType string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+	event := Event{SiteID: siteID, Type: eventType, Data: data, CreatedAt: time.Now().UTC()}
+	result, err := b.events.InsertOne(ctx, event)
+	if err != nil {
+		log.Printf("events: publish %s for site %s: %v", eventType, siteID.Hex(), err)
+		return
+	}
+	event.ID, _ = result.InsertedID.(primitive.ObjectID)
+
+	b.mu.Lock()
+	hooks := b.hooks
+	b.mu.Unlock()
+	for _, fn := range hooks {
+		if err := fn(ctx, event); err != nil {
+			log.Printf("events: handle %s for site %s: %v", eventType, siteID.Hex(), err)
+		}
+	}
+}
+
+// OnPublish registers fn to be called with every event published through
+// this replica, before Publish returns. Unlike listeners, fn does not depend
+// on the event still being in the collection when it is polled, so it suits
+// work that must not be lost. Errors are logged. Registering on a nil bus is
+// a no-op.
+func (b *Bus) OnPublish(fn func(ctx context.Context, event Event) error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.hooks = append(b.hooks, fn)
+	b.mu.Unlock()
+}
+
+// Subscribe returns a channel of events for one site and a function that
+// cancels the subscription. Slow subscribers miss events rather than block
+// the bus.
+func (b *Bus) Subscribe(siteID primitive.ObjectID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers[siteID] == nil {
+		b.subscribers[siteID] = map[chan Event]struct{}{}
+	}
+	b.subscribers[siteID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[siteID], ch)
+			if len(b.subscribers[siteID]) == 0 {
+				delete(b.subscribers, siteID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Listen registers fn to be called with the events of every site. Unlike
+// subscribers, listeners see every event; fn runs on the polling goroutine
+// and must not block. Listening on a nil bus is a no-op.
+func (b *Bus) Listen(fn func(Event)) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.listeners = append(b.listeners, fn)
+	b.mu.Unlock()
+}
+
+// Run polls for new events until ctx is cancelled.
+func (b *Bus) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	since := time.Now().UTC()
+	delivered := map[primitive.ObjectID]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latest, err := b.poll(ctx, since.Add(-pollOverlap), delivered)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("events: poll: %v", err)
+			}
+			continue
+		}
+		if latest.After(since) {
+			since = latest
+		}
+		for id, at := range delivered {
+			if at.Before(since.Add(-2 * pollOverlap)) {
+				delete(delivered, id)
+			}
+		}
+	}
+}
+
+func (b *Bus) poll(ctx context.Context, from time.Time, delivered map[primitive.ObjectID]time.Time) (time.Time, error) {
+	var latest time.Time
+	cursor, err := b.events.Find(ctx, bson.M{"createdAt": bson.M{"$gte": from}}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return latest, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return latest, err
+		}
+		if event.CreatedAt.After(latest) {
+			latest = event.CreatedAt
+		}
+		if _, seen := delivered[event.ID]; seen {
+			continue
+		}
+		delivered[event.ID] = event.CreatedAt
+		b.dispatch(event)
+	}
+	return latest, cursor.Err()
+}
+
+func (b *Bus) dispatch(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, fn := range b.listeners {
+		fn(event)
+	}
+	for ch := range b.subscribers[event.SiteID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
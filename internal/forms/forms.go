@@ -0,0 +1,179 @@
+// Package forms validates site form definitions and the submissions visitors
+// send to them.
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
+
+const (
+	TypeText     = "text"
+	TypeEmail    = "email"
+	TypeTel      = "tel"
+	TypeTextarea = "textarea"
+	TypeSelect   = "select"
+	TypeCheckbox = "checkbox"
+
+	// HoneypotField is a field real visitors leave empty because the site
+	// hides it. Field names cannot start with an underscore, so it never
+	// collides with a form field.
+	HoneypotField = "_hp"
+
+	// MaxBodyBytes caps the size of a submission request.
+	MaxBodyBytes = 64 << 10
+
+	defaultMaxLength  = 500
+	textareaMaxLength = 5000
+)
+
+var telPattern = regexp.MustCompile(`^[0-9+()./ -]{3,30}$`)
+
+var fieldSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []interface{}{"name", "label", "type"},
+	"properties": map[string]interface{}{
+		"name":      map[string]interface{}{"type": "string", "pattern": `^[a-z][a-zA-Z0-9_]{0,39}$`},
+		"label":     map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 120},
+		"type":      map[string]interface{}{"type": "string", "enum": []interface{}{TypeText, TypeEmail, TypeTel, TypeTextarea, TypeSelect, TypeCheckbox}},
+		"required":  map[string]interface{}{"type": "boolean"},
+		"options":   map[string]interface{}{"type": "array", "maxItems": 50, "items": map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 100}},
+		"maxLength": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": textareaMaxLength},
+	},
+}
+
+var schema = map[string]interface{}{
+	"type":     "object",
+	"required": []interface{}{"name", "fields"},
+	"properties": map[string]interface{}{
+		"name":        map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 100},
+		"fields":      map[string]interface{}{"type": "array", "minItems": 1, "maxItems": 30, "items": fieldSchema},
+		"notifyEmail": map[string]interface{}{"type": "string", "maxLength": 254, "format": "email"},
+	},
+}
+
+// Normalize trims the text of form.
+func Normalize(form models.Form) models.Form {
+	form.Name = strings.TrimSpace(form.Name)
+	form.NotifyEmail = strings.ToLower(strings.TrimSpace(form.NotifyEmail))
+	for i := range form.Fields {
+		f := &form.Fields[i]
+		f.Name = strings.TrimSpace(f.Name)
+		f.Label = strings.TrimSpace(f.Label)
+		for j := range f.Options {
+			f.Options[j] = strings.TrimSpace(f.Options[j])
+		}
+	}
+	return form
+}
+
+// Validate checks the definition of form.
+func Validate(form models.Form) []sections.ValidationError {
+	raw, err := json.Marshal(form)
+	if err != nil {
+		return []sections.ValidationError{{Path: "", Message: err.Error()}}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return []sections.ValidationError{{Path: "", Message: err.Error()}}
+	}
+	errs := sections.Validate(schema, doc, "")
+	seen := map[string]bool{}
+	for i, f := range form.Fields {
+		path := fmt.Sprintf("/fields/%d", i)
+		if seen[f.Name] {
+			errs = append(errs, sections.ValidationError{Path: path + "/name", Message: "duplicate field name " + f.Name})
+		}
+		seen[f.Name] = true
+		if f.Type == TypeSelect && len(f.Options) == 0 {
+			errs = append(errs, sections.ValidationError{Path: path + "/options", Message: "select fields need options"})
+		}
+	}
+	return errs
+}
+
+// Clean checks values submitted to form and returns the trimmed, non-empty
+// values of its fields. Values of unknown fields are dropped. Checked
+// checkboxes are stored as "true".
+func Clean(form models.Form, values map[string]string) (map[string]string, []sections.ValidationError) {
+	var errs []sections.ValidationError
+	fail := func(f models.FormField, message string) {
+		errs = append(errs, sections.ValidationError{Path: "/" + f.Name, Message: message})
+	}
+	data := map[string]string{}
+	for _, f := range form.Fields {
+		value := strings.TrimSpace(values[f.Name])
+		if f.Type == TypeCheckbox {
+			switch strings.ToLower(value) {
+			case "", "false", "0", "off", "no":
+				value = ""
+			default:
+				value = "true"
+			}
+		}
+		if value == "" {
+			if f.Required {
+				fail(f, "is required")
+			}
+			continue
+		}
+		if n := len([]rune(value)); n > maxLength(f) {
+			fail(f, fmt.Sprintf("must be at most %d characters", maxLength(f)))
+			continue
+		}
+		switch f.Type {
+		case TypeEmail:
+			if len(sections.Validate(map[string]interface{}{"format": "email"}, value, "")) > 0 || strings.ContainsAny(value, "\r\n,;<>") {
+				fail(f, "must be an email address")
+				continue
+			}
+		case TypeTel:
+			if !telPattern.MatchString(value) {
+				fail(f, "must be a phone number")
+				continue
+			}
+		case TypeSelect:
+			if !contains(f.Options, value) {
+				fail(f, "must be one of the options")
+				continue
+			}
+		}
+		data[f.Name] = value
+	}
+	return data, errs
+}
+
+// ReplyTo returns the first email address in data, so notifications can be
+// answered directly.
+func ReplyTo(form models.Form, data map[string]string) string {
+	for _, f := range form.Fields {
+		if f.Type == TypeEmail && data[f.Name] != "" {
+			return data[f.Name]
+		}
+	}
+	return ""
+}
+
+func maxLength(f models.FormField) int {
+	switch {
+	case f.MaxLength > 0:
+		return f.MaxLength
+	case f.Type == TypeTextarea:
+		return textareaMaxLength
+	}
+	return defaultMaxLength
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
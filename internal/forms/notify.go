@@ -0,0 +1,130 @@
+package forms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/mail"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	NotifyPending = "pending"
+	NotifySent    = "sent"
+	NotifyFailed  = "failed"
+
+	// MaxNotifyAttempts is how often a notification is tried before it fails.
+	MaxNotifyAttempts = 5
+
+	notifyBatch = 20
+	// notifyClaim keeps a notification from other senders while it is sent.
+	notifyClaim = 2 * time.Minute
+	notifyRetry = 5 * time.Minute
+)
+
+// Notifier emails site owners about new submissions.
+type Notifier struct {
+	Submissions *mongo.Collection
+	Forms       *mongo.Collection
+	Sites       *mongo.Collection
+	Mailer      mail.Sender
+}
+
+// SendDue sends the notifications that are due. Failures are retried a few
+// times, a little later each time.
+func (n *Notifier) SendDue(ctx context.Context) error {
+	for i := 0; i < notifyBatch; i++ {
+		now := time.Now().UTC()
+		var submission models.FormSubmission
+		err := n.Submissions.FindOneAndUpdate(ctx,
+			bson.M{"notification.status": NotifyPending, "notification.nextAttemptAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"notification.nextAttemptAt": now.Add(notifyClaim)}},
+			options.FindOneAndUpdate().SetSort(bson.D{{Key: "notification.nextAttemptAt", Value: 1}}).SetReturnDocument(options.After),
+		).Decode(&submission)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("claim notification: %w", err)
+		}
+		if err := n.notify(ctx, submission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) notify(ctx context.Context, submission models.FormSubmission) error {
+	var form models.Form
+	if err := n.Forms.FindOne(ctx, bson.M{"_id": submission.FormID}).Decode(&form); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("find form %s: %w", submission.FormID.Hex(), err)
+	}
+	var site models.Site
+	if err := n.Sites.FindOne(ctx, bson.M{"_id": submission.SiteID}, options.FindOne().SetProjection(bson.M{"name": 1})).Decode(&site); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("find site %s: %w", submission.SiteID.Hex(), err)
+	}
+
+	msg := mail.Message{
+		To:      submission.Notification.To,
+		ReplyTo: ReplyTo(form, submission.Data),
+		Subject: fmt.Sprintf("%s: %s", site.Name, submission.FormName),
+		Body:    notificationBody(site, form, submission),
+	}
+	sendErr := n.Mailer.Send(ctx, msg)
+
+	now := time.Now().UTC()
+	attempts := submission.Notification.Attempts + 1
+	set := bson.M{"notification.attempts": attempts}
+	unset := bson.M{}
+	switch {
+	case sendErr == nil:
+		set["notification.status"] = NotifySent
+		set["notification.sentAt"] = now
+		unset["notification.nextAttemptAt"] = ""
+		unset["notification.lastError"] = ""
+	case attempts >= MaxNotifyAttempts:
+		set["notification.status"] = NotifyFailed
+		set["notification.lastError"] = sendErr.Error()
+		unset["notification.nextAttemptAt"] = ""
+	default:
+		set["notification.lastError"] = sendErr.Error()
+		set["notification.nextAttemptAt"] = now.Add(time.Duration(attempts) * notifyRetry)
+	}
+	if _, err := n.Submissions.UpdateOne(ctx, bson.M{"_id": submission.ID}, bson.M{"$set": set, "$unset": unset}); err != nil {
+		return fmt.Errorf("record notification of submission %s: %w", submission.ID.Hex(), err)
+	}
+	return nil
+}
+
+// notificationBody lists the submitted values in form order, labelled.
+// Values of fields no longer on the form follow under their names.
+func notificationBody(site models.Site, form models.Form, submission models.FormSubmission) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "New submission to %q on %s.\n\n", submission.FormName, site.Name)
+	listed := map[string]bool{}
+	for _, f := range form.Fields {
+		listed[f.Name] = true
+		if value, ok := submission.Data[f.Name]; ok {
+			fmt.Fprintf(&b, "%s:\n%s\n\n", f.Label, value)
+		}
+	}
+	var rest []string
+	for name := range submission.Data {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		fmt.Fprintf(&b, "%s:\n%s\n\n", name, submission.Data[name])
+	}
+	fmt.Fprintf(&b, "Received %s.\n", submission.CreatedAt.Format(time.RFC1123))
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package forms
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Visitors may submit RateLimit forms per site in every RateWindow.
+const (
+	RateLimit  = 5
+	RateWindow = 10 * time.Minute
+)
+
+// Limiter allows Max hits per key in fixed windows. Counts are kept in Mongo
+// so the limit holds across replicas; the collection needs a TTL index on
+// expiresAt.
+type Limiter struct {
+	Hits   *mongo.Collection
+	Max    int
+	Window time.Duration
+}
+
+// Allow counts a hit for key and reports whether it is within the limit,
+// and otherwise how long until the window resets.
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := time.Now().UTC()
+	start := now.Truncate(l.Window)
+	id := key + ":" + strconv.FormatInt(start.Unix(), 10)
+	update := bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"expiresAt": start.Add(l.Window)}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var hit struct {
+		Count int `bson:"count"`
+	}
+	err := l.Hits.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&hit)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two first hits raced to insert the window; the loser just counts.
+		err = l.Hits.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&hit)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return hit.Count <= l.Max, start.Add(l.Window).Sub(now), nil
+}
@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/slugs"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +21,9 @@ type AdminHandler struct {
 	Users           *mongo.Collection
 	Sites           *mongo.Collection
 	SitePermissions *mongo.Collection
+	SlugRedirects   *mongo.Collection
+	Templates       *mongo.Collection
+	Events          *events.Bus
 }
 
 type grantSiteRequest struct {
@@ -37,7 +42,7 @@ func (h *AdminHandler) ListSites(c *gin.Context) {
 	(&SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}).List(c)
 }
 func (h *AdminHandler) CreateSite(c *gin.Context) {
-	(&SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}).Create(c)
+	(&SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions, SlugRedirects: h.SlugRedirects, Templates: h.Templates}).Create(c)
 }
 
 func (h *AdminHandler) GrantSiteAccess(c *gin.Context) {
@@ -82,7 +87,7 @@ func (h *AdminHandler) GrantSiteAccess(c *gin.Context) {
 	}
 
 	now := time.Now().UTC()
-	_, err = h.SitePermissions.UpdateOne(c,
+	result, err := h.SitePermissions.UpdateOne(c,
 		bson.M{"siteId": siteID, "userId": user.ID},
 		bson.M{"$set": bson.M{"role": role, "updatedAt": now}, "$setOnInsert": bson.M{"createdAt": now}},
 		options.Update().SetUpsert(true),
@@ -91,6 +96,9 @@ func (h *AdminHandler) GrantSiteAccess(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "failed to grant access")
 		return
 	}
+	if result.UpsertedCount > 0 {
+		h.Events.Publish(c, siteID, events.MemberAdded, map[string]interface{}{"userId": user.ID.Hex(), "email": user.Email, "role": role})
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "granted"})
 }
 
@@ -184,8 +192,29 @@ func (h *AdminHandler) CreateSiteDirect(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "invalid slug")
 		return
 	}
+	reserved, err := slugs.Reserved(c, h.SlugRedirects, slug, primitive.NilObjectID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check slug")
+		return
+	}
+	if reserved {
+		respondError(c, http.StatusConflict, "slug already exists")
+		return
+	}
+	content := map[string]interface{}{}
+	if req.TemplateID != "" {
+		content, err = starterContent(c, h.Templates, req.TemplateID)
+		if err == errTemplateNotFound {
+			respondError(c, http.StatusBadRequest, "template not found")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to load template")
+			return
+		}
+	}
 	now := time.Now().UTC()
-	site := models.Site{Name: req.Name, Slug: slug, Status: "draft", Content: map[string]interface{}{}, CreatedAt: now, UpdatedAt: now}
+	site := models.Site{Name: req.Name, Slug: slug, Status: "draft", Content: content, HasUnpublishedChanges: len(content) > 0, CreatedAt: now, UpdatedAt: now}
 	res, err := h.Sites.InsertOne(c, site)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
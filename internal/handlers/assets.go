@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/imaging"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// assetExtensions maps the accepted upload types, sniffed from the bytes
+// rather than trusted from the client, to their file extensions. SVG is left
+// out because it can carry scripts.
+var assetExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type AssetHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Assets          *mongo.Collection
+	Storage         storage.Storage
+	Cache           *imaging.DiskCache
+	MaxUploadBytes  int64
+	BaseURL         string
+}
+
+type updateAssetRequest struct {
+	Alt *string `json:"alt"`
+}
+
+func (h *AssetHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *AssetHandler) List(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, false)
+	if !ok {
+		return
+	}
+	cursor, err := h.Assets.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch assets")
+		return
+	}
+	defer cursor.Close(c)
+	assets := []models.Asset{}
+	if err := cursor.All(c, &assets); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode assets")
+		return
+	}
+	for i := range assets {
+		h.setURL(&assets[i])
+	}
+	c.JSON(http.StatusOK, assets)
+}
+
+// Upload stores the multipart "file" field. An optional "alt" field sets the
+// alt text.
+func (h *AssetHandler) Upload(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	userID, _ := getUserID(c)
+
+	// Leave room for the multipart framing around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxUploadBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(c, http.StatusRequestEntityTooLarge, "file too large")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	if header.Size > h.MaxUploadBytes {
+		respondError(c, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid file")
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid file")
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := assetExtensions[contentType]
+	if !ok {
+		respondError(c, http.StatusUnsupportedMediaType, "unsupported file type")
+		return
+	}
+
+	// Metadata is stripped and orientation applied before anything is
+	// stored, so the original never leaves the request.
+	processed, err := imaging.Process(data, contentType)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		respondError(c, http.StatusUnprocessableEntity, "image dimensions too large")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid image")
+		return
+	}
+
+	now := time.Now().UTC()
+	asset := models.Asset{
+		ID:          primitive.NewObjectID(),
+		SiteID:      siteID,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(processed.Data)),
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Alt:         strings.TrimSpace(c.PostForm("alt")),
+		UploadedBy:  userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	base := "sites/" + siteID.Hex() + "/" + asset.ID.Hex()
+	asset.Key = base + ext
+	if err := h.Storage.Put(c, asset.Key, bytes.NewReader(processed.Data), asset.Size, contentType); err != nil {
+		log.Printf("assets: store %s: %v", asset.Key, err)
+		respondError(c, http.StatusInternalServerError, "failed to store asset")
+		return
+	}
+	for _, v := range processed.Variants {
+		variant := models.AssetVariant{Name: v.Name, Key: fmt.Sprintf("%s_%s%s", base, v.Name, ext), Width: v.Width, Height: v.Height, Size: int64(len(v.Data))}
+		if err := h.Storage.Put(c, variant.Key, bytes.NewReader(v.Data), variant.Size, contentType); err != nil {
+			log.Printf("assets: store %s: %v", variant.Key, err)
+			h.deleteObjects(c, asset)
+			respondError(c, http.StatusInternalServerError, "failed to store asset")
+			return
+		}
+		asset.Variants = append(asset.Variants, variant)
+	}
+	if _, err := h.Assets.InsertOne(c, asset); err != nil {
+		h.deleteObjects(c, asset)
+		respondError(c, http.StatusInternalServerError, "failed to create asset")
+		return
+	}
+	h.setURL(&asset)
+	c.JSON(http.StatusCreated, asset)
+}
+
+func (h *AssetHandler) Update(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	assetID, err := primitive.ObjectIDFromHex(c.Param("assetId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid asset id")
+		return
+	}
+	var req updateAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Alt == nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	var asset models.Asset
+	err = h.Assets.FindOneAndUpdate(c,
+		bson.M{"_id": assetID, "siteId": siteID},
+		bson.M{"$set": bson.M{"alt": strings.TrimSpace(*req.Alt), "updatedAt": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&asset)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "asset not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update asset")
+		return
+	}
+	h.setURL(&asset)
+	c.JSON(http.StatusOK, asset)
+}
+
+// Delete removes the stored bytes before the record, so a failure leaves the
+// asset listed and the delete can be retried.
+func (h *AssetHandler) Delete(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	assetID, err := primitive.ObjectIDFromHex(c.Param("assetId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid asset id")
+		return
+	}
+	var asset models.Asset
+	if err := h.Assets.FindOne(c, bson.M{"_id": assetID, "siteId": siteID}).Decode(&asset); err != nil {
+		respondError(c, http.StatusNotFound, "asset not found")
+		return
+	}
+	if err := h.deleteObjects(c, asset); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete asset")
+		return
+	}
+	if _, err := h.Assets.DeleteOne(c, bson.M{"_id": asset.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete asset")
+		return
+	}
+	if err := h.Cache.RemovePrefix(asset.ID.Hex() + "_"); err != nil {
+		log.Printf("assets: clear resize cache of %s: %v", asset.ID.Hex(), err)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// Serve streams an asset without authentication, since assets are embedded
+// in public sites. The extension in the URL is cosmetic. For JPEG and PNG
+// images, ?w= returns a stored variant of that width or renders one on
+// demand. On-demand widths are rounded up to one of imaging.ResizeWidths, so
+// unauthenticated requests cannot make the server render and cache an
+// unbounded number of sizes.
+func (h *AssetHandler) Serve(c *gin.Context) {
+	name := c.Param("name")
+	assetID, err := primitive.ObjectIDFromHex(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "asset not found")
+		return
+	}
+	width := 0
+	if raw := c.Query("w"); raw != "" {
+		width, err = strconv.Atoi(raw)
+		if err != nil || width < 1 || width > imaging.MaxWidth {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("w must be between 1 and %d", imaging.MaxWidth))
+			return
+		}
+	}
+	var asset models.Asset
+	if err := h.Assets.FindOne(c, bson.M{"_id": assetID}).Decode(&asset); err != nil {
+		respondError(c, http.StatusNotFound, "asset not found")
+		return
+	}
+
+	// Keys never change, so every response can be cached for good.
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	if width == 0 || width >= asset.Width || !imaging.Resizable(asset.ContentType) {
+		h.stream(c, asset.Key, asset.Size, asset.ContentType)
+		return
+	}
+	if variant, ok := findVariant(asset, width); ok {
+		h.stream(c, variant.Key, variant.Size, asset.ContentType)
+		return
+	}
+	width, ok := imaging.ResizeWidth(width)
+	if !ok || width >= asset.Width {
+		h.stream(c, asset.Key, asset.Size, asset.ContentType)
+		return
+	}
+	if variant, ok := findVariant(asset, width); ok {
+		h.stream(c, variant.Key, variant.Size, asset.ContentType)
+		return
+	}
+
+	cacheName := fmt.Sprintf("%s_w%d%s", asset.ID.Hex(), width, filepath.Ext(asset.Key))
+	if data, ok := h.Cache.Get(cacheName); ok {
+		c.Data(http.StatusOK, asset.ContentType, data)
+		return
+	}
+	body, err := h.Storage.Open(c, asset.Key)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to read asset")
+		return
+	}
+	original, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to read asset")
+		return
+	}
+	data, err := imaging.Resize(original, asset.ContentType, width)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to resize asset")
+		return
+	}
+	if err := h.Cache.Put(cacheName, data); err != nil {
+		log.Printf("assets: cache %s: %v", cacheName, err)
+	}
+	c.Data(http.StatusOK, asset.ContentType, data)
+}
+
+func (h *AssetHandler) stream(c *gin.Context, key string, size int64, contentType string) {
+	body, err := h.Storage.Open(c, key)
+	if err == storage.ErrNotFound {
+		respondError(c, http.StatusNotFound, "asset not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to read asset")
+		return
+	}
+	defer body.Close()
+	c.DataFromReader(http.StatusOK, size, contentType, body, nil)
+}
+
+func findVariant(asset models.Asset, width int) (models.AssetVariant, bool) {
+	for _, v := range asset.Variants {
+		if v.Width == width {
+			return v, true
+		}
+	}
+	return models.AssetVariant{}, false
+}
+
+// deleteObjects removes the stored original and variants of asset.
+func (h *AssetHandler) deleteObjects(c *gin.Context, asset models.Asset) error {
+	keys := []string{asset.Key}
+	for _, v := range asset.Variants {
+		keys = append(keys, v.Key)
+	}
+	for _, key := range keys {
+		if err := h.Storage.Delete(c, key); err != nil {
+			log.Printf("assets: delete %s: %v", key, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *AssetHandler) setURL(asset *models.Asset) {
+	asset.URL = h.BaseURL + "/assets/" + asset.ID.Hex() + filepath.Ext(asset.Key)
+	for i := range asset.Variants {
+		asset.Variants[i].URL = fmt.Sprintf("%s?w=%d", asset.URL, asset.Variants[i].Width)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/bundle"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const maxBundleSize = 20 << 20
+
+// Export downloads the site as a bundle archive.
+func (h *SiteHandler) Export(c *gin.Context) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, bundle.FromSite(site, now)); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to export site")
+		return
+	}
+	filename := fmt.Sprintf("%s-%s.zip", site.Slug, now.Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// Import creates a draft site owned by the caller from an uploaded bundle,
+// sent either as the multipart "file" field or as the raw request body. Like
+// Create, it is reserved for superadmins.
+func (h *SiteHandler) Import(c *gin.Context) {
+	globalRole, _ := getGlobalRole(c)
+	if globalRole != "superadmin" {
+		respondError(c, http.StatusForbidden, "superadmin access required")
+		return
+	}
+	userID, err := getUserID(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid user")
+		return
+	}
+	onConflict := c.DefaultQuery("onConflict", bundle.OnConflictRename)
+	if onConflict != bundle.OnConflictRename && onConflict != bundle.OnConflictFail {
+		respondError(c, http.StatusBadRequest, "onConflict must be rename or fail")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleSize)
+	var data []byte
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid bundle")
+			return
+		}
+		data, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid bundle")
+			return
+		}
+	} else {
+		data, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusRequestEntityTooLarge, "bundle too large")
+			return
+		}
+	}
+
+	b, err := bundle.Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	importer := &bundle.Importer{Sites: h.Sites, SitePermissions: h.SitePermissions, SlugRedirects: h.SlugRedirects}
+	site, err := importer.Import(c, b, bundle.ImportOptions{Slug: c.Query("slug"), OnConflict: onConflict, OwnerID: userID})
+	if err != nil {
+		var contentErr *bundle.ContentError
+		switch {
+		case errors.As(err, &contentErr):
+			respondInvalidContent(c, contentErr.Errors)
+		case errors.Is(err, bundle.ErrInvalidSlug):
+			respondError(c, http.StatusBadRequest, "invalid slug")
+		case errors.Is(err, bundle.ErrSlugConflict):
+			respondError(c, http.StatusConflict, "slug already exists")
+		default:
+			respondError(c, http.StatusInternalServerError, "failed to import site")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, site)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxCommentLength = 5000
+
+// CommentHandler manages comment threads. Viewers can read threads; editors
+// and owners can start, reply to, resolve and reopen them.
+type CommentHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Users           *mongo.Collection
+	CommentThreads  *mongo.Collection
+}
+
+type commentRequest struct {
+	Body     string                `json:"body" binding:"required"`
+	Mentions []string              `json:"mentions"`
+	Anchor   *models.CommentAnchor `json:"anchor"`
+}
+
+func (h *CommentHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+// canRead reports whether userID can read the site, with the same rules
+// as canReadCurrentUser: superadmins read every site, other users need a
+// permission on it.
+func (h *CommentHandler) canRead(c *gin.Context, userID, siteID primitive.ObjectID) (bool, error) {
+	var user models.User
+	err := h.Users.FindOne(c, bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"globalRole": 1})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.GlobalRole == "superadmin" {
+		return true, nil
+	}
+	return h.sites().canReadSite(c, userID, siteID)
+}
+
+func (h *CommentHandler) List(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, false)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"siteId": siteID}
+	switch c.DefaultQuery("status", "all") {
+	case "open":
+		filter["resolved"] = false
+	case "resolved":
+		filter["resolved"] = true
+	case "all":
+	default:
+		respondError(c, http.StatusBadRequest, "status must be open, resolved or all")
+		return
+	}
+	if section := c.Query("sectionId"); section != "" {
+		filter["anchor.sectionId"] = section
+	}
+
+	cursor, err := h.CommentThreads.Find(c, filter, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch comments")
+		return
+	}
+	defer cursor.Close(c)
+	threads := []models.CommentThread{}
+	if err := cursor.All(c, &threads); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode comments")
+		return
+	}
+	c.JSON(http.StatusOK, threads)
+}
+
+func (h *CommentHandler) Create(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	comment, anchor, ok := h.bindComment(c, siteID)
+	if !ok {
+		return
+	}
+	if anchor != nil {
+		anchor.SectionID = strings.TrimSpace(anchor.SectionID)
+		anchor.Path = strings.TrimSpace(anchor.Path)
+		if anchor.Path != "" && !strings.HasPrefix(anchor.Path, "/") {
+			respondError(c, http.StatusBadRequest, "anchor path must be a JSON pointer")
+			return
+		}
+		if anchor.SectionID == "" && anchor.Path == "" {
+			anchor = nil
+		}
+	}
+
+	thread := models.CommentThread{
+		SiteID:    siteID,
+		Anchor:    anchor,
+		Comments:  []models.Comment{comment},
+		CreatedBy: comment.AuthorID,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.CreatedAt,
+	}
+	res, err := h.CommentThreads.InsertOne(c, thread)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create comment")
+		return
+	}
+	thread.ID = res.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, thread)
+}
+
+func (h *CommentHandler) Reply(c *gin.Context) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	threadID, err := primitive.ObjectIDFromHex(c.Param("threadId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid thread id")
+		return
+	}
+	comment, _, ok := h.bindComment(c, siteID)
+	if !ok {
+		return
+	}
+
+	var thread models.CommentThread
+	err = h.CommentThreads.FindOneAndUpdate(c,
+		bson.M{"_id": threadID, "siteId": siteID},
+		bson.M{"$push": bson.M{"comments": comment}, "$set": bson.M{"updatedAt": comment.CreatedAt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&thread)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "thread not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to add reply")
+		return
+	}
+	c.JSON(http.StatusCreated, thread)
+}
+
+func (h *CommentHandler) Resolve(c *gin.Context) { h.setResolved(c, true) }
+func (h *CommentHandler) Reopen(c *gin.Context)  { h.setResolved(c, false) }
+
+func (h *CommentHandler) setResolved(c *gin.Context, resolved bool) {
+	siteID, ok := h.sites().requireAccess(c, true)
+	if !ok {
+		return
+	}
+	threadID, err := primitive.ObjectIDFromHex(c.Param("threadId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid thread id")
+		return
+	}
+	userID, _ := getUserID(c)
+
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{"resolved": false, "updatedAt": now}, "$unset": bson.M{"resolvedBy": "", "resolvedAt": ""}}
+	if resolved {
+		update = bson.M{"$set": bson.M{"resolved": true, "resolvedBy": userID, "resolvedAt": now, "updatedAt": now}}
+	}
+	var thread models.CommentThread
+	err = h.CommentThreads.FindOneAndUpdate(c, bson.M{"_id": threadID, "siteId": siteID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&thread)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "thread not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update thread")
+		return
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+// bindComment reads a comment from the request body. Mentions must refer to
+// members of the site.
+func (h *CommentHandler) bindComment(c *gin.Context, siteID primitive.ObjectID) (models.Comment, *models.CommentAnchor, bool) {
+	var req commentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return models.Comment{}, nil, false
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		respondError(c, http.StatusBadRequest, "body must be between 1 and 5000 characters")
+		return models.Comment{}, nil, false
+	}
+
+	mentions := make([]primitive.ObjectID, 0, len(req.Mentions))
+	for _, raw := range req.Mentions {
+		mentionID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid mention id")
+			return models.Comment{}, nil, false
+		}
+		if containsObjectID(mentions, mentionID) {
+			continue
+		}
+		reader, err := h.canRead(c, mentionID, siteID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to check mentions")
+			return models.Comment{}, nil, false
+		}
+		if !reader {
+			respondError(c, http.StatusBadRequest, "mentioned users must be able to read the site")
+			return models.Comment{}, nil, false
+		}
+		mentions = append(mentions, mentionID)
+	}
+
+	userID, _ := getUserID(c)
+	comment := models.Comment{
+		ID:        primitive.NewObjectID(),
+		AuthorID:  userID,
+		Body:      body,
+		Mentions:  mentions,
+		CreatedAt: time.Now().UTC(),
+	}
+	return comment, req.Anchor, true
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/jsonpatch"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mimeJSONPatch  = "application/json-patch+json"
+	mimeMergePatch = "application/merge-patch+json"
+
+	maxPatchBytes = 1 << 20
+	// Unconditional patches are re-applied on a fresh read when another
+	// write slips in between the read and the compare-and-swap.
+	maxPatchAttempts = 3
+)
+
+// PatchContent applies a JSON Patch or JSON Merge Patch to the draft content.
+// The patch is applied to the stored version and written back only if that
+// version is still current, so concurrent edits to other fields survive.
+func (h *SiteHandler) PatchContent(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != mimeJSONPatch && mediaType != mimeMergePatch {
+		respondError(c, http.StatusUnsupportedMediaType, "content type must be "+mimeJSONPatch+" or "+mimeMergePatch)
+		return
+	}
+	patch, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBytes))
+	if err != nil {
+		respondError(c, http.StatusRequestEntityTooLarge, "patch too large")
+		return
+	}
+
+	expected, conditional, err := parseIfMatch(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		var site models.Site
+		err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"content": 1, "version": 1})).Decode(&site)
+		if err == mongo.ErrNoDocuments {
+			respondError(c, http.StatusNotFound, "site not found")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to fetch site")
+			return
+		}
+		if conditional && site.Version != expected {
+			respondVersionConflict(c, site.Version)
+			return
+		}
+
+		content, err := applyContentPatch(site.Content, patch, mediaType)
+		if err != nil {
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				respondError(c, http.StatusConflict, err.Error())
+				return
+			}
+			respondError(c, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		content = pages.Normalize(content)
+		if errs := pages.ValidateContent(content); len(errs) > 0 {
+			respondInvalidContent(c, errs)
+			return
+		}
+		if !h.checkLocks(c, siteID, site.Content, content) {
+			return
+		}
+
+		version, err := h.saveContent(c, bson.M{"_id": siteID, "version": site.Version}, content)
+		if err == mongo.ErrNoDocuments {
+			if conditional {
+				h.respondWriteMiss(c, siteID, true)
+				return
+			}
+			continue
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update content")
+			return
+		}
+		h.publishContentUpdated(c, siteID, version)
+		c.Header("ETag", siteETag(version))
+		c.JSON(http.StatusOK, gin.H{"status": "updated", "version": version, "content": content})
+		return
+	}
+	respondError(c, http.StatusConflict, "site is being modified concurrently, retry")
+}
+
+// applyContentPatch runs the patch against a JSON copy of the stored content
+// and checks that the result is still a content object. The result holds plain
+// JSON values so it can be validated directly.
+func applyContentPatch(current map[string]interface{}, patch []byte, mediaType string) (map[string]interface{}, error) {
+	if current == nil {
+		current = map[string]interface{}{}
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	if mediaType == mimeJSONPatch {
+		patched, err = jsonpatch.Apply(doc, patch)
+	} else {
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(patched, &content); err != nil || content == nil {
+		return nil, errors.New("patched content must be a JSON object")
+	}
+	return content, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DomainHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Domains         *mongo.Collection
+	Verifier        *domains.Verifier
+	// PlatformDomains are our own domains; neither they nor their
+	// subdomains can be attached to a site.
+	PlatformDomains []string
+}
+
+type createDomainRequest struct {
+	Hostname string `json:"hostname" binding:"required"`
+}
+
+// domainResponse adds the DNS record the owner has to create.
+type domainResponse struct {
+	models.SiteDomain
+	Record dnsRecord `json:"record"`
+}
+
+type dnsRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (h *DomainHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func newDomainResponse(d models.SiteDomain) domainResponse {
+	return domainResponse{SiteDomain: d, Record: dnsRecord{Type: "TXT", Name: domains.RecordName(d.Hostname), Value: domains.RecordValue(d.VerificationToken)}}
+}
+
+func (h *DomainHandler) List(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	cursor, err := h.Domains.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch domains")
+		return
+	}
+	defer cursor.Close(c)
+	var list []models.SiteDomain
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode domains")
+		return
+	}
+	response := make([]domainResponse, 0, len(list))
+	for _, d := range list {
+		response = append(response, newDomainResponse(d))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Create attaches a hostname as pending. Several sites may claim the same
+// hostname; only the one that proves ownership gets it.
+func (h *DomainHandler) Create(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	var req createDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	hostname, ok := domains.NormalizeHostname(req.Hostname)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "invalid hostname")
+		return
+	}
+	for _, platform := range h.PlatformDomains {
+		if hostname == platform || strings.HasSuffix(hostname, "."+platform) {
+			respondError(c, http.StatusBadRequest, "hostname belongs to the platform")
+			return
+		}
+	}
+
+	token, err := utils.NewSecretToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create domain")
+		return
+	}
+	now := time.Now().UTC()
+	domain := models.SiteDomain{
+		SiteID:            siteID,
+		Hostname:          hostname,
+		Status:            domains.StatusPending,
+		VerificationToken: token,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	result, err := h.Domains.InsertOne(c, domain)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(c, http.StatusConflict, "domain already attached")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "failed to create domain")
+		return
+	}
+	domain.ID = result.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, newDomainResponse(domain))
+}
+
+// Verify checks the TXT record right away instead of waiting for the
+// background job.
+func (h *DomainHandler) Verify(c *gin.Context) {
+	domain, ok := h.findDomain(c)
+	if !ok {
+		return
+	}
+	if err := h.Verifier.Verify(c, &domain); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to verify domain")
+		return
+	}
+	c.JSON(http.StatusOK, newDomainResponse(domain))
+}
+
+func (h *DomainHandler) Delete(c *gin.Context) {
+	domain, ok := h.findDomain(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Domains.DeleteOne(c, bson.M{"_id": domain.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete domain")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func (h *DomainHandler) findDomain(c *gin.Context) (models.SiteDomain, bool) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return models.SiteDomain{}, false
+	}
+	domainID, err := primitive.ObjectIDFromHex(c.Param("domainId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid domain id")
+		return models.SiteDomain{}, false
+	}
+	var domain models.SiteDomain
+	if err := h.Domains.FindOne(c, bson.M{"_id": domainID, "siteId": siteID}).Decode(&domain); err != nil {
+		respondError(c, http.StatusNotFound, "domain not found")
+		return models.SiteDomain{}, false
+	}
+	return domain, true
+}
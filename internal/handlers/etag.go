@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidIfMatch = errors.New("invalid If-Match header")
+
+// siteETag formats a site version as a strong entity tag.
+func siteETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the site version the client expects. ok is false when
+// the header is absent or "*", in which case the write is unconditional.
+func parseIfMatch(c *gin.Context) (version int64, ok bool, err error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, false, nil
+	}
+	value = strings.TrimPrefix(value, "W/")
+	value = strings.Trim(value, `"`)
+	version, err = strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return 0, false, errInvalidIfMatch
+	}
+	return version, true, nil
+}
+
+func respondVersionConflict(c *gin.Context, current int64) {
+	c.Header("ETag", siteETag(current))
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "site was modified by someone else", "version": current})
+}
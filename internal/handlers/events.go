@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	// Presence entries outlive a few missed heartbeats; the TTL index removes
+	// entries left behind by replicas that died.
+	presenceTTL = 3 * streamHeartbeat
+	// Open streams re-check access this often, so removed members stop
+	// receiving events.
+	streamAccessCheck = time.Minute
+	streamTicketTTL   = time.Minute
+)
+
+// EventHandler streams site events to the panel over Server-Sent Events and
+// tracks which users currently have a site open.
+type EventHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Users           *mongo.Collection
+	Presence        *mongo.Collection
+	StreamTickets   *mongo.Collection
+	Events          *events.Bus
+}
+
+type presenceEntry struct {
+	ID        string             `bson:"_id" json:"-"`
+	SiteID    primitive.ObjectID `bson:"siteId" json:"-"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Email     string             `bson:"email" json:"email"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"-"`
+}
+
+// Ticket issues a single-use ticket that opens the event stream of the site
+// for the caller. EventSource cannot send an Authorization header, and a
+// ticket keeps the access token itself out of URLs and access logs.
+func (h *EventHandler) Ticket(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.sites().canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+	userID, _ := getUserID(c)
+	role, _ := getGlobalRole(c)
+	raw, err := utils.NewSecretToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to issue ticket")
+		return
+	}
+	ticket := models.StreamTicket{ID: utils.HashToken(raw), SiteID: siteID, UserID: userID, GlobalRole: role, ExpiresAt: time.Now().UTC().Add(streamTicketTTL)}
+	if _, err := h.StreamTickets.InsertOne(c, ticket); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to issue ticket")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"ticket": raw, "expiresAt": ticket.ExpiresAt})
+}
+
+// Stream sends the events of the site until the client leaves or loses
+// access to the site.
+func (h *EventHandler) Stream(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.sites().canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+	userID, _ := getUserID(c)
+	var user models.User
+	if err := h.Users.FindOne(c, bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"email": 1})).Decode(&user); err != nil {
+		respondError(c, http.StatusUnauthorized, "user not found")
+		return
+	}
+
+	stream, unsubscribe := h.Events.Subscribe(siteID)
+	defer unsubscribe()
+
+	ctx := c.Request.Context()
+	presence := presenceEntry{ID: primitive.NewObjectID().Hex(), SiteID: siteID, UserID: userID, Email: user.Email}
+	if err := h.touchPresence(ctx, &presence); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to register presence")
+		return
+	}
+	h.publishPresence(ctx, siteID)
+	defer func() {
+		// The request context is already cancelled when the client leaves.
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = h.Presence.DeleteOne(cleanupCtx, bson.M{"_id": presence.ID})
+		h.publishPresence(cleanupCtx, siteID)
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if users, err := h.presentUsers(ctx, siteID); err == nil {
+		writeEvent(c, events.Event{SiteID: siteID, Type: events.Presence, Data: map[string]interface{}{"users": users}, CreatedAt: time.Now().UTC()})
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	accessCheck := time.NewTicker(streamAccessCheck)
+	defer accessCheck.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-stream:
+			writeEvent(c, event)
+		case <-accessCheck.C:
+			// A failed check keeps the stream; only a revoked grant ends it.
+			if allowed, err := h.sites().canReadCurrentUser(c, siteID); err == nil && !allowed {
+				return
+			}
+		case <-heartbeat.C:
+			if err := h.touchPresence(ctx, &presence); err != nil && ctx.Err() == nil {
+				return
+			}
+			_, _ = fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func (h *EventHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *EventHandler) touchPresence(ctx context.Context, entry *presenceEntry) error {
+	entry.ExpiresAt = time.Now().UTC().Add(presenceTTL)
+	_, err := h.Presence.ReplaceOne(ctx, bson.M{"_id": entry.ID}, entry, options.Replace().SetUpsert(true))
+	return err
+}
+
+// publishPresence tells every replica who currently has the site open.
+func (h *EventHandler) publishPresence(ctx context.Context, siteID primitive.ObjectID) {
+	users, err := h.presentUsers(ctx, siteID)
+	if err != nil {
+		return
+	}
+	h.Events.Publish(ctx, siteID, events.Presence, map[string]interface{}{"users": users})
+}
+
+// presentUsers lists the distinct users with a live connection to the site.
+func (h *EventHandler) presentUsers(ctx context.Context, siteID primitive.ObjectID) ([]presenceEntry, error) {
+	cursor, err := h.Presence.Find(ctx, bson.M{"siteId": siteID, "expiresAt": bson.M{"$gt": time.Now().UTC()}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var entries []presenceEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	users := make([]presenceEntry, 0, len(entries))
+	seen := map[primitive.ObjectID]bool{}
+	for _, entry := range entries {
+		if seen[entry.UserID] {
+			continue
+		}
+		seen[entry.UserID] = true
+		users = append(users, entry)
+	}
+	return users, nil
+}
+
+func writeEvent(c *gin.Context, event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if !event.ID.IsZero() {
+		_, _ = fmt.Fprintf(c.Writer, "id: %s\n", event.ID.Hex())
+	}
+	_, _ = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, payload)
+	c.Writer.Flush()
+}
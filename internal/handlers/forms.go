@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxFormsPerSite    = 20
+	submissionPageSize = 50
+)
+
+type FormHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Forms           *mongo.Collection
+	Submissions     *mongo.Collection
+}
+
+type formRequest struct {
+	Name        string             `json:"name"`
+	Fields      []models.FormField `json:"fields"`
+	NotifyEmail string             `json:"notifyEmail"`
+}
+
+type updateSubmissionRequest struct {
+	Read     *bool `json:"read"`
+	Archived *bool `json:"archived"`
+}
+
+func (h *FormHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *FormHandler) List(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	cursor, err := h.Forms.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch forms")
+		return
+	}
+	defer cursor.Close(c)
+	list := []models.Form{}
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode forms")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *FormHandler) Get(c *gin.Context) {
+	form, ok := h.findForm(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, form)
+}
+
+func (h *FormHandler) Create(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	form, ok := bindForm(c)
+	if !ok {
+		return
+	}
+	count, err := h.Forms.CountDocuments(c, bson.M{"siteId": siteID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create form")
+		return
+	}
+	if count >= maxFormsPerSite {
+		respondError(c, http.StatusConflict, "too many forms")
+		return
+	}
+	now := time.Now().UTC()
+	form.SiteID = siteID
+	form.CreatedAt = now
+	form.UpdatedAt = now
+	result, err := h.Forms.InsertOne(c, form)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create form")
+		return
+	}
+	form.ID = result.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, form)
+}
+
+// Update replaces the definition of a form. Existing submissions keep the
+// values they were sent with.
+func (h *FormHandler) Update(c *gin.Context) {
+	existing, ok := h.findForm(c)
+	if !ok {
+		return
+	}
+	form, ok := bindForm(c)
+	if !ok {
+		return
+	}
+	form.ID = existing.ID
+	form.SiteID = existing.SiteID
+	form.CreatedAt = existing.CreatedAt
+	form.UpdatedAt = time.Now().UTC()
+	update := bson.M{"$set": bson.M{"name": form.Name, "fields": form.Fields, "notifyEmail": form.NotifyEmail, "updatedAt": form.UpdatedAt}}
+	if _, err := h.Forms.UpdateOne(c, bson.M{"_id": form.ID}, update); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update form")
+		return
+	}
+	c.JSON(http.StatusOK, form)
+}
+
+// Delete removes a form. Its submissions stay in the inbox.
+func (h *FormHandler) Delete(c *gin.Context) {
+	form, ok := h.findForm(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Forms.DeleteOne(c, bson.M{"_id": form.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete form")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListSubmissions is the inbox: submissions newest first, a page at a time.
+// status is inbox (default, not archived), unread, archived or all; before
+// takes the id of the last submission of the previous page.
+func (h *FormHandler) ListSubmissions(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	filter := bson.M{"siteId": siteID}
+	switch c.DefaultQuery("status", "inbox") {
+	case "inbox":
+		filter["archivedAt"] = nil
+	case "unread":
+		filter["archivedAt"] = nil
+		filter["readAt"] = nil
+	case "archived":
+		filter["archivedAt"] = bson.M{"$ne": nil}
+	case "all":
+	default:
+		respondError(c, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if raw := c.Query("formId"); raw != "" {
+		formID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid form id")
+			return
+		}
+		filter["formId"] = formID
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before")
+			return
+		}
+		filter["_id"] = bson.M{"$lt": before}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(submissionPageSize)
+	cursor, err := h.Submissions.Find(c, filter, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch submissions")
+		return
+	}
+	defer cursor.Close(c)
+	list := []models.FormSubmission{}
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode submissions")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// UpdateSubmission marks a submission read or unread and archives or
+// restores it.
+func (h *FormHandler) UpdateSubmission(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	submissionID, err := primitive.ObjectIDFromHex(c.Param("submissionId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid submission id")
+		return
+	}
+	var req updateSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	now := time.Now().UTC()
+	set, unset := bson.M{}, bson.M{}
+	if req.Read != nil {
+		if *req.Read {
+			set["readAt"] = now
+		} else {
+			unset["readAt"] = ""
+		}
+	}
+	if req.Archived != nil {
+		if *req.Archived {
+			set["archivedAt"] = now
+		} else {
+			unset["archivedAt"] = ""
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		respondError(c, http.StatusBadRequest, "nothing to update")
+		return
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var submission models.FormSubmission
+	err = h.Submissions.FindOneAndUpdate(c, bson.M{"_id": submissionID, "siteId": siteID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&submission)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "submission not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update submission")
+		return
+	}
+	c.JSON(http.StatusOK, submission)
+}
+
+func (h *FormHandler) DeleteSubmission(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	submissionID, err := primitive.ObjectIDFromHex(c.Param("submissionId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid submission id")
+		return
+	}
+	result, err := h.Submissions.DeleteOne(c, bson.M{"_id": submissionID, "siteId": siteID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete submission")
+		return
+	}
+	if result.DeletedCount == 0 {
+		respondError(c, http.StatusNotFound, "submission not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// Export downloads every submission of a form as CSV, oldest first. Columns
+// follow the current fields of the form, then values of fields that have
+// since been removed.
+func (h *FormHandler) Export(c *gin.Context) {
+	form, ok := h.findForm(c)
+	if !ok {
+		return
+	}
+	cursor, err := h.Submissions.Find(c, bson.M{"formId": form.ID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch submissions")
+		return
+	}
+	defer cursor.Close(c)
+	var list []models.FormSubmission
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode submissions")
+		return
+	}
+
+	columns := make([]string, 0, len(form.Fields))
+	header := []string{"id", "submittedAt", "read", "archived"}
+	known := map[string]bool{}
+	for _, f := range form.Fields {
+		columns = append(columns, f.Name)
+		header = append(header, f.Label)
+		known[f.Name] = true
+	}
+	var removed []string
+	for _, s := range list {
+		for name := range s.Data {
+			if !known[name] {
+				known[name] = true
+				removed = append(removed, name)
+			}
+		}
+	}
+	sort.Strings(removed)
+	columns = append(columns, removed...)
+	header = append(header, removed...)
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="form-%s.csv"`, form.ID.Hex()))
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	for _, s := range list {
+		row := []string{s.ID.Hex(), s.CreatedAt.Format(time.RFC3339), fmt.Sprint(s.ReadAt != nil), fmt.Sprint(s.ArchivedAt != nil)}
+		for _, name := range columns {
+			row = append(row, csvSafe(s.Data[name]))
+		}
+		_ = w.Write(row)
+	}
+	w.Flush()
+}
+
+func (h *FormHandler) findForm(c *gin.Context) (models.Form, bool) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return models.Form{}, false
+	}
+	formID, err := primitive.ObjectIDFromHex(c.Param("formId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid form id")
+		return models.Form{}, false
+	}
+	var form models.Form
+	if err := h.Forms.FindOne(c, bson.M{"_id": formID, "siteId": siteID}).Decode(&form); err != nil {
+		respondError(c, http.StatusNotFound, "form not found")
+		return models.Form{}, false
+	}
+	return form, true
+}
+
+func bindForm(c *gin.Context) (models.Form, bool) {
+	var req formRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return models.Form{}, false
+	}
+	form := forms.Normalize(models.Form{Name: req.Name, Fields: req.Fields, NotifyEmail: req.NotifyEmail})
+	if errs := forms.Validate(form); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid form", "details": errs})
+		return models.Form{}, false
+	}
+	return form, true
+}
+
+// csvSafe keeps spreadsheet applications from evaluating visitor input as a
+// formula.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
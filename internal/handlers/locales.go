@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/locales"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxAdditionalLocales = 10
+
+type updateLocalesRequest struct {
+	Default    string   `json:"default" binding:"required"`
+	Additional []string `json:"additional"`
+}
+
+// UpdateLocales sets the default locale of the site and the additional
+// locales it is translated into.
+func (h *SiteHandler) UpdateLocales(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	role, err := h.currentSiteRole(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if role != "superadmin" && role != "owner" {
+		respondError(c, http.StatusForbidden, "owner access required")
+		return
+	}
+
+	var req updateLocalesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	def, ok := locales.Normalize(req.Default)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "invalid default locale")
+		return
+	}
+	if len(req.Additional) > maxAdditionalLocales {
+		respondError(c, http.StatusBadRequest, "too many locales")
+		return
+	}
+	settings := models.SiteLocales{Default: def, Additional: []string{}}
+	seen := map[string]bool{def: true}
+	for _, raw := range req.Additional {
+		tag, ok := locales.Normalize(raw)
+		if !ok {
+			respondError(c, http.StatusBadRequest, "invalid locale "+strings.TrimSpace(raw))
+			return
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		settings.Additional = append(settings.Additional, tag)
+	}
+
+	now := time.Now().UTC()
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$set": bson.M{"locales": settings, "updatedAt": now, "settingsUpdatedAt": now}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update locales")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	h.Events.Publish(c, siteID, events.SiteUpdated, map[string]interface{}{"locales": settings})
+	c.JSON(http.StatusOK, settings)
+}
+
+// siteLocales returns the default locale of site and all of its locales,
+// default first.
+func siteLocales(site models.Site) (string, []string) {
+	if site.Locales == nil || site.Locales.Default == "" {
+		return locales.Default, []string{locales.Default}
+	}
+	return site.Locales.Default, append([]string{site.Locales.Default}, site.Locales.Additional...)
+}
+
+// resolveLocale picks the locale of a public request. A leading path segment
+// naming one of the site's additional locales selects it and is stripped from
+// path; otherwise Accept-Language decides, falling back to the default.
+// negotiated reports whether the answer depends on Accept-Language.
+func resolveLocale(c *gin.Context, site models.Site, path string) (locale, rest string, negotiated bool) {
+	def, all := siteLocales(site)
+	if len(all) == 1 {
+		return def, path, false
+	}
+	segment, remainder, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	for _, tag := range all[1:] {
+		if segment == tag {
+			return tag, "/" + remainder, false
+		}
+	}
+	if tag := locales.Negotiate(c.GetHeader("Accept-Language"), all); tag != "" {
+		return tag, path, true
+	}
+	return def, path, true
+}
+
+// localeBase is the URL prefix of the site's pages in locale.
+func localeBase(base, locale, def string) string {
+	if locale == def {
+		return base
+	}
+	return base + "/" + locale
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lockTTL is how long a lock survives without a heartbeat.
+const lockTTL = 60 * time.Second
+
+// LockHandler manages advisory edit locks. Editors take and renew locks,
+// everyone with access can see them, and owners can break stale ones.
+type LockHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Users           *mongo.Collection
+	Locks           *mongo.Collection
+	Events          *events.Bus
+}
+
+type acquireLockRequest struct {
+	Section string `json:"section"`
+}
+
+func (h *LockHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *LockHandler) List(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.sites().canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+	locks, err := activeLocks(c, h.Locks, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch locks")
+		return
+	}
+	c.JSON(http.StatusOK, locks)
+}
+
+// Acquire takes a lock or renews one the caller already holds. Clients call
+// it again as a heartbeat well within lockTTL.
+func (h *LockHandler) Acquire(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.sites().canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+	var req acquireLockRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request")
+			return
+		}
+	}
+	section := strings.TrimSpace(req.Section)
+	userID, _ := getUserID(c)
+
+	locks, err := activeLocks(c, h.Locks, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch locks")
+		return
+	}
+	// A whole-site lock and section locks of other users exclude each other.
+	for _, lock := range locks {
+		if lock.HolderID == userID {
+			continue
+		}
+		if lock.Section == section || lock.Section == "" || section == "" {
+			respondLocked(c, []models.SiteLock{lock})
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.Users.FindOne(c, bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"email": 1})).Decode(&user); err != nil {
+		respondError(c, http.StatusUnauthorized, "user not found")
+		return
+	}
+
+	// Mongo keeps milliseconds; truncating lets the comparison below work.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var lock models.SiteLock
+	err = h.Locks.FindOneAndUpdate(c,
+		bson.M{
+			"siteId":  siteID,
+			"section": section,
+			"$or":     bson.A{bson.M{"holderId": userID}, bson.M{"expiresAt": bson.M{"$lte": now}}},
+		},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"acquiredAt":  bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$holderId", userID}}, "$acquiredAt", now}},
+			"holderId":    userID,
+			"holderEmail": user.Email,
+			"expiresAt":   now.Add(lockTTL),
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&lock)
+	if mongo.IsDuplicateKeyError(err) {
+		// Someone else grabbed it between the check and the upsert.
+		current, _ := activeLocks(c, h.Locks, siteID)
+		respondLocked(c, current)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to acquire lock")
+		return
+	}
+	if lock.AcquiredAt.Equal(now) {
+		h.Events.Publish(c, siteID, events.LocksChanged, map[string]interface{}{"section": section, "holderId": userID.Hex(), "action": "acquired"})
+	}
+	c.JSON(http.StatusOK, lock)
+}
+
+// Release drops the caller's lock. Owners can pass force=true to break a
+// lock held by someone else.
+func (h *LockHandler) Release(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	role, err := h.sites().currentSiteRole(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if role != "superadmin" && role != "owner" && role != "editor" {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+	userID, _ := getUserID(c)
+	section := strings.TrimSpace(c.Query("section"))
+
+	filter := bson.M{"siteId": siteID, "section": section}
+	if c.Query("force") == "true" {
+		if role != "superadmin" && role != "owner" {
+			respondError(c, http.StatusForbidden, "owner access required to break locks")
+			return
+		}
+	} else {
+		filter["holderId"] = userID
+	}
+	result, err := h.Locks.DeleteOne(c, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to release lock")
+		return
+	}
+	if result.DeletedCount == 0 {
+		respondError(c, http.StatusNotFound, "lock not found")
+		return
+	}
+	h.Events.Publish(c, siteID, events.LocksChanged, map[string]interface{}{"section": section, "holderId": userID.Hex(), "action": "released"})
+	c.JSON(http.StatusOK, gin.H{"status": "released"})
+}
+
+func activeLocks(ctx context.Context, locks *mongo.Collection, siteID primitive.ObjectID) ([]models.SiteLock, error) {
+	cursor, err := locks.Find(ctx, bson.M{"siteId": siteID, "expiresAt": bson.M{"$gt": time.Now().UTC()}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	out := []models.SiteLock{}
+	if err := cursor.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func respondLocked(c *gin.Context, locks []models.SiteLock) {
+	c.JSON(http.StatusLocked, gin.H{"error": "locked by another user", "locks": locks})
+}
+
+// lockedSectionChanges returns the locks of other users that replacing the
+// current content with next would violate: a whole-site lock, or a section
+// lock whose section is added, removed or modified. current is only loaded
+// when someone else holds a lock. Locks are advisory, so the check is not
+// atomic with the write that follows it.
+func lockedSectionChanges(ctx context.Context, locks *mongo.Collection, siteID, userID primitive.ObjectID, current func() (map[string]interface{}, error), next map[string]interface{}) ([]models.SiteLock, error) {
+	active, err := activeLocks(ctx, locks, siteID)
+	if err != nil {
+		return nil, err
+	}
+	var held []models.SiteLock
+	for _, lock := range active {
+		if lock.HolderID != userID {
+			held = append(held, lock)
+		}
+	}
+	if len(held) == 0 {
+		return nil, nil
+	}
+
+	currentContent, err := current()
+	if err != nil {
+		return nil, err
+	}
+	before, err := sectionsByID(currentContent)
+	if err != nil {
+		return nil, err
+	}
+	after, err := sectionsByID(next)
+	if err != nil {
+		return nil, err
+	}
+	var violated []models.SiteLock
+	for _, lock := range held {
+		if lock.Section == "" || !reflect.DeepEqual(before[lock.Section], after[lock.Section]) {
+			violated = append(violated, lock)
+		}
+	}
+	return violated, nil
+}
+
+// sectionsByID indexes the sections of every page of a content document by
+// their id. Translations usually reuse the id of the section they translate,
+// so each id maps to all of its sections. The content is normalized through
+// JSON so stored and submitted content compare equal when they hold the same
+// values.
+func sectionsByID(content map[string]interface{}) (map[string][]map[string]interface{}, error) {
+	doc, err := pages.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]map[string]interface{}{}
+	for _, section := range doc.Sections() {
+		if id, ok := section["id"].(string); ok && id != "" {
+			out[id] = append(out[id], section)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type pageRequest struct {
+	Path  string     `json:"path" binding:"required"`
+	Title string     `json:"title"`
+	Order *int       `json:"order"`
+	SEO   *pages.SEO `json:"seo"`
+	// Sections and translations are kept as they are when omitted on update.
+	Sections     []map[string]interface{}     `json:"sections"`
+	Translations map[string]pages.Translation `json:"translations"`
+}
+
+type navigationRequest struct {
+	Navigation []pages.NavItem `json:"navigation" binding:"required"`
+}
+
+// pageEditError rejects a page edit with the given status.
+type pageEditError struct {
+	status  int
+	message string
+}
+
+func (h *SiteHandler) ListPages(c *gin.Context) {
+	site, doc, ok := h.readPages(c)
+	if !ok {
+		return
+	}
+	c.Header("ETag", siteETag(site.Version))
+	c.JSON(http.StatusOK, gin.H{"pages": doc.Ordered(), "navigation": doc.Navigation})
+}
+
+func (h *SiteHandler) GetPage(c *gin.Context) {
+	site, doc, ok := h.readPages(c)
+	if !ok {
+		return
+	}
+	page, found := doc.ByID(c.Param("pageId"))
+	if !found {
+		respondError(c, http.StatusNotFound, "page not found")
+		return
+	}
+	c.Header("ETag", siteETag(site.Version))
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *SiteHandler) CreatePage(c *gin.Context) {
+	var req pageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	var created pages.Page
+	h.editPages(c, http.StatusCreated, func(doc *pages.Document) *pageEditError {
+		created = pages.Page{
+			ID:           uniquePageID(*doc, req.Path),
+			Path:         pages.CleanPath(req.Path),
+			Title:        strings.TrimSpace(req.Title),
+			SEO:          req.SEO,
+			Sections:     req.Sections,
+			Translations: req.Translations,
+		}
+		if req.Order != nil {
+			created.Order = *req.Order
+		} else {
+			for _, p := range doc.Pages {
+				if p.Order >= created.Order {
+					created.Order = p.Order + 1
+				}
+			}
+		}
+		if created.Sections == nil {
+			created.Sections = []map[string]interface{}{}
+		}
+		doc.Pages = append(doc.Pages, created)
+		return nil
+	}, func() gin.H { return gin.H{"page": created} })
+}
+
+// UpdatePage replaces the path, title, order and SEO fields of a page. Order,
+// sections and translations are kept when omitted.
+func (h *SiteHandler) UpdatePage(c *gin.Context) {
+	var req pageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	pageID := c.Param("pageId")
+	var updated pages.Page
+	h.editPages(c, http.StatusOK, func(doc *pages.Document) *pageEditError {
+		for i := range doc.Pages {
+			page := &doc.Pages[i]
+			if page.ID != pageID {
+				continue
+			}
+			page.Path = pages.CleanPath(req.Path)
+			page.Title = strings.TrimSpace(req.Title)
+			page.SEO = req.SEO
+			if req.Order != nil {
+				page.Order = *req.Order
+			}
+			if req.Sections != nil {
+				page.Sections = req.Sections
+			}
+			if req.Translations != nil {
+				page.Translations = req.Translations
+			}
+			updated = *page
+			return nil
+		}
+		return &pageEditError{http.StatusNotFound, "page not found"}
+	}, func() gin.H { return gin.H{"page": updated} })
+}
+
+// DeletePage removes a page and the menu entries linking to it. The home
+// page cannot be deleted.
+func (h *SiteHandler) DeletePage(c *gin.Context) {
+	pageID := c.Param("pageId")
+	h.editPages(c, http.StatusOK, func(doc *pages.Document) *pageEditError {
+		for i, page := range doc.Pages {
+			if page.ID != pageID {
+				continue
+			}
+			if page.Path == pages.HomePath {
+				return &pageEditError{http.StatusConflict, "the home page cannot be deleted"}
+			}
+			doc.Pages = append(doc.Pages[:i], doc.Pages[i+1:]...)
+			doc.Navigation = withoutPage(doc.Navigation, pageID)
+			return nil
+		}
+		return &pageEditError{http.StatusNotFound, "page not found"}
+	}, nil)
+}
+
+func (h *SiteHandler) UpdateNavigation(c *gin.Context) {
+	var req navigationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	h.editPages(c, http.StatusOK, func(doc *pages.Document) *pageEditError {
+		doc.Navigation = req.Navigation
+		return nil
+	}, func() gin.H { return gin.H{"navigation": req.Navigation} })
+}
+
+func (h *SiteHandler) readPages(c *gin.Context) (models.Site, pages.Document, bool) {
+	var site models.Site
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return site, pages.Document{}, false
+	}
+	allowed, err := h.canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return site, pages.Document{}, false
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return site, pages.Document{}, false
+	}
+	err = h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"content": 1, "version": 1})).Decode(&site)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return site, pages.Document{}, false
+	}
+	doc, err := pages.Parse(site.Content)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to read pages")
+		return site, pages.Document{}, false
+	}
+	return site, doc, true
+}
+
+// editPages applies edit to the pages of the stored draft and writes the
+// result back with the same compare-and-swap as PatchContent. The fields
+// returned by result, when set, are added to the response.
+func (h *SiteHandler) editPages(c *gin.Context, status int, edit func(doc *pages.Document) *pageEditError, result func() gin.H) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+	expected, conditional, err := parseIfMatch(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		var site models.Site
+		err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"content": 1, "version": 1})).Decode(&site)
+		if err == mongo.ErrNoDocuments {
+			respondError(c, http.StatusNotFound, "site not found")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to fetch site")
+			return
+		}
+		if conditional && site.Version != expected {
+			respondVersionConflict(c, site.Version)
+			return
+		}
+
+		// Stored content that is not a pages document has to be replaced
+		// through the content endpoints before pages can be edited.
+		doc, err := pages.Parse(site.Content)
+		if err != nil {
+			respondError(c, http.StatusUnprocessableEntity, "site content is not a valid pages document")
+			return
+		}
+		if editErr := edit(&doc); editErr != nil {
+			respondError(c, editErr.status, editErr.message)
+			return
+		}
+		content, err := withPages(site.Content, doc)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update pages")
+			return
+		}
+		if errs := pages.ValidateContent(content); len(errs) > 0 {
+			respondInvalidContent(c, errs)
+			return
+		}
+		if !h.checkLocks(c, siteID, site.Content, content) {
+			return
+		}
+
+		version, err := h.saveContent(c, bson.M{"_id": siteID, "version": site.Version}, content)
+		if err == mongo.ErrNoDocuments {
+			if conditional {
+				h.respondWriteMiss(c, siteID, true)
+				return
+			}
+			continue
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update pages")
+			return
+		}
+		h.publishContentUpdated(c, siteID, version)
+		c.Header("ETag", siteETag(version))
+		body := gin.H{"status": "updated", "version": version}
+		if result != nil {
+			for key, value := range result() {
+				body[key] = value
+			}
+		}
+		c.JSON(status, body)
+		return
+	}
+	respondError(c, http.StatusConflict, "site is being modified concurrently, retry")
+}
+
+// withPages returns a plain JSON copy of content holding the pages and
+// navigation of doc. Other top-level fields are kept.
+func withPages(content map[string]interface{}, doc pages.Document) (map[string]interface{}, error) {
+	if doc.Pages == nil {
+		doc.Pages = []pages.Page{}
+	}
+	for i := range doc.Pages {
+		if doc.Pages[i].Sections == nil {
+			doc.Pages[i].Sections = []map[string]interface{}{}
+		}
+	}
+	out := map[string]interface{}{}
+	if err := jsonCopy(pages.Normalize(content), &out); err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := jsonCopy(doc, &fields); err != nil {
+		return nil, err
+	}
+	out["pages"] = fields["pages"]
+	if doc.Navigation != nil {
+		out["navigation"] = fields["navigation"]
+	} else {
+		delete(out, "navigation")
+	}
+	return out, nil
+}
+
+func jsonCopy(from, to interface{}) error {
+	raw, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, to)
+}
+
+// uniquePageID derives an id from the last segment of path, numbering it
+// when it is taken.
+func uniquePageID(doc pages.Document, path string) string {
+	path = pages.CleanPath(path)
+	base := pages.HomeID
+	if path != pages.HomePath {
+		base = path[strings.LastIndex(path, "/")+1:]
+	}
+	id := base
+	for n := 2; ; n++ {
+		if _, taken := doc.ByID(id); !taken {
+			return id
+		}
+		id = base + "-" + strconv.Itoa(n)
+	}
+}
+
+func withoutPage(items []pages.NavItem, pageID string) []pages.NavItem {
+	out := []pages.NavItem{}
+	for _, item := range items {
+		if item.PageID == pageID {
+			continue
+		}
+		item.Children = withoutPage(item.Children, pageID)
+		if len(item.Children) == 0 {
+			item.Children = nil
+		}
+		out = append(out, item)
+	}
+	return out
+}
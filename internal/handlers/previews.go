@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultPreviewTTL = 72 * time.Hour
+	maxPreviewTTL     = 30 * 24 * time.Hour
+)
+
+type PreviewHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	PreviewTokens   *mongo.Collection
+}
+
+type createPreviewRequest struct {
+	TTLHours int    `json:"ttlHours"`
+	Label    string `json:"label"`
+}
+
+func (h *PreviewHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *PreviewHandler) Create(c *gin.Context) {
+	siteID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	userID, _ := getUserID(c)
+
+	var req createPreviewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request")
+			return
+		}
+	}
+	ttl := defaultPreviewTTL
+	if req.TTLHours != 0 {
+		ttl = time.Duration(req.TTLHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxPreviewTTL {
+		respondError(c, http.StatusBadRequest, "ttlHours must be between 1 and 720")
+		return
+	}
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"slug": 1})).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+
+	token, err := utils.NewSecretToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create preview token")
+		return
+	}
+	now := time.Now().UTC()
+	preview := models.PreviewToken{
+		SiteID:    siteID,
+		TokenHash: utils.HashToken(token),
+		Label:     strings.TrimSpace(req.Label),
+		CreatedBy: userID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	res, err := h.PreviewTokens.InsertOne(c, preview)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create preview token")
+		return
+	}
+	preview.ID = res.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"preview": preview,
+		"token":   token,
+		"url":     "/s/" + site.Slug + "?preview=" + url.QueryEscape(token),
+	})
+}
+
+func (h *PreviewHandler) List(c *gin.Context) {
+	siteID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	cursor, err := h.PreviewTokens.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch preview tokens")
+		return
+	}
+	defer cursor.Close(c)
+	previews := []models.PreviewToken{}
+	if err := cursor.All(c, &previews); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode preview tokens")
+		return
+	}
+	c.JSON(http.StatusOK, previews)
+}
+
+func (h *PreviewHandler) Revoke(c *gin.Context) {
+	siteID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	previewID, err := primitive.ObjectIDFromHex(c.Param("previewId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid preview id")
+		return
+	}
+	result, err := h.PreviewTokens.UpdateOne(c,
+		bson.M{"_id": previewID, "siteId": siteID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to revoke preview token")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "preview token not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
+
+// authorize parses the site id and checks that the caller may manage its
+// previews, which is the same as being allowed to edit it.
+func (h *PreviewHandler) authorize(c *gin.Context) (primitive.ObjectID, bool) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return primitive.NilObjectID, false
+	}
+	allowed, err := h.sites().canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return primitive.NilObjectID, false
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return primitive.NilObjectID, false
+	}
+	return siteID, true
+}
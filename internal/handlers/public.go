@@ -1,29 +1,358 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/locales"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/render"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sitecache"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type PublicHandler struct {
-	Sites *mongo.Collection
+	Sites         *mongo.Collection
+	PreviewTokens *mongo.Collection
+	SlugRedirects *mongo.Collection
+	Domains       *mongo.Collection
+	Forms         *mongo.Collection
+	Submissions   *mongo.Collection
+	// BaseURL is the public origin of the API, used in absolute links. The
+	// request host is used when it is empty.
+	BaseURL string
+	// Cache holds recently served published sites; nil disables it.
+	Cache *sitecache.Cache
+	// CacheControl is sent with published pages.
+	CacheControl string
+	// FormLimiter caps form submissions per visitor.
+	FormLimiter *forms.Limiter
+	// NotifyForms queues email notifications of form submissions; it is
+	// off when no mail server is configured.
+	NotifyForms bool
 }
 
 func (h *PublicHandler) GetPublishedSite(c *gin.Context) {
-	slug := c.Param("slug")
-	var site models.Site
-	if err := h.Sites.FindOne(c, bson.M{"slug": slug, "status": "published"}).Decode(&site); err != nil {
-		respondError(c, http.StatusNotFound, "site not found")
+	h.servePath(c, c.Param("slug"), pages.HomePath)
+}
+
+// GetPublishedPage serves the page of a site at the wildcard path, or one of
+// the site's crawler files.
+func (h *PublicHandler) GetPublishedPage(c *gin.Context) {
+	h.servePath(c, c.Param("slug"), c.Param("path"))
+}
+
+func (h *PublicHandler) servePath(c *gin.Context, slug, path string) {
+	filter := bson.M{"slug": slug}
+	if name, ok := seoFileName(path); ok {
+		h.serveSEOFile(c, "slug:"+slug, filter, "", name)
+		return
+	}
+	h.serveSite(c, "slug:"+slug, filter, "/s/"+slug, path, func() bool { return h.redirectRenamed(c, slug, path) })
+}
+
+// ServeCustomDomain is the router fallback. It serves the site whose
+// verified custom domain matches the Host header and 404s everything else.
+func (h *PublicHandler) ServeCustomDomain(c *gin.Context) {
+	formID, isForm := customDomainFormID(c.Request.Method, c.Request.URL.Path)
+	if !isForm && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		respondError(c, http.StatusNotFound, "not found")
+		return
+	}
+	hostname, ok := domains.NormalizeHostname(c.Request.Host)
+	if !ok {
+		respondError(c, http.StatusNotFound, "not found")
 		return
 	}
+	var domain models.SiteDomain
+	if err := h.Domains.FindOne(c, bson.M{"hostname": hostname, "status": domains.StatusVerified}).Decode(&domain); err != nil {
+		respondError(c, http.StatusNotFound, "not found")
+		return
+	}
+	filter := bson.M{"_id": domain.SiteID}
+	if isForm {
+		h.submitForm(c, "id:"+domain.SiteID.Hex(), filter, formID)
+		return
+	}
+	path := c.Request.URL.Path
+	if name, ok := seoFileName(path); ok {
+		h.serveSEOFile(c, "id:"+domain.SiteID.Hex(), filter, hostname, name)
+		return
+	}
+	h.serveSite(c, "id:"+domain.SiteID.Hex(), filter, "", path, nil)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"slug":    site.Slug,
-		"content": site.Content,
-		"updated": site.UpdatedAt,
+// serveSite answers with the page at path of the published site matching
+// filter, or of its draft when a preview token is given. Published sites are
+// cached under cacheKey. base is the prefix of the site's page URLs.
+// notFound, when set, gets a chance to respond before the 404.
+func (h *PublicHandler) serveSite(c *gin.Context, cacheKey string, filter bson.M, base, path string, notFound func() bool) {
+	if token := c.Query("preview"); token != "" {
+		h.getPreview(c, filter, token, base, path, notFound)
+		return
+	}
+
+	site, err := h.publishedSite(c, cacheKey, filter)
+	if err == mongo.ErrNoDocuments {
+		if notFound == nil || !notFound() {
+			respondError(c, http.StatusNotFound, "site not found")
+		}
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch site")
+		return
+	}
+
+	h.respondSite(c, site, site.PublishedContent, base, path, "")
+}
+
+// publishedSite returns the published site matching filter, from the cache
+// when possible. The draft is not loaded.
+func (h *PublicHandler) publishedSite(c *gin.Context, cacheKey string, filter bson.M) (models.Site, error) {
+	if site, ok := h.Cache.Get(cacheKey); ok {
+		return site, nil
+	}
+	epoch := h.Cache.Epoch()
+	var site models.Site
+	err := h.Sites.FindOne(c,
+		bson.M{"$and": []bson.M{filter, {"status": "published"}}},
+		options.FindOne().SetProjection(bson.M{"content": 0, "slugHistory": 0}),
+	).Decode(&site)
+	if err != nil {
+		return site, err
+	}
+	h.Cache.Add(cacheKey, site, epoch)
+	return site, nil
+}
+
+// getPreview serves the draft content to holders of a valid preview token.
+// Previews must never be indexed or cached by intermediaries.
+func (h *PublicHandler) getPreview(c *gin.Context, filter bson.M, token, base, path string, notFound func() bool) {
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"$and": []bson.M{filter, {"deletedAt": nil}}}).Decode(&site); err != nil {
+		if notFound == nil || !notFound() {
+			respondError(c, http.StatusNotFound, "site not found")
+		}
+		return
+	}
+	count, err := h.PreviewTokens.CountDocuments(c, bson.M{
+		"siteId":    site.ID,
+		"tokenHash": utils.HashToken(token),
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+		"revokedAt": bson.M{"$exists": false},
 	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check preview token")
+		return
+	}
+	if count == 0 {
+		respondError(c, http.StatusNotFound, "preview not found or expired")
+		return
+	}
+
+	h.respondSite(c, site, site.Content, base, path, token)
+}
+
+// respondSite renders the page at path as HTML for browsers and as JSON for
+// everyone else. API clients that send no Accept header, or */*, keep getting
+// JSON. ?format=html or ?format=json overrides the header. previewToken is
+// set when the draft is served and is carried over to navigation links.
+// Published pages can be cached and revalidated; previews cannot.
+func (h *PublicHandler) respondSite(c *gin.Context, site models.Site, content map[string]interface{}, base, path, previewToken string) {
+	vary := "Accept"
+	locale, path, negotiated := resolveLocale(c, site, path)
+	if negotiated {
+		vary += ", Accept-Language"
+	}
+	c.Header("Vary", vary)
+	def, all := siteLocales(site)
+	base = localeBase(base, locale, def)
+
+	doc, err := pages.Parse(content)
+	if err != nil {
+		log.Printf("public: parse pages of %s: %v", site.Slug, err)
+		respondError(c, http.StatusInternalServerError, "failed to read site")
+		return
+	}
+	page, ok := doc.Find(path)
+	if !ok {
+		respondError(c, http.StatusNotFound, "page not found")
+		return
+	}
+	page = page.Localized(locale)
+	nav := pages.LocalizeNav(doc.Navigation, locale)
+	rtl := locales.IsRTL(locale)
+	c.Header("Content-Language", locale)
+	preview := previewToken != ""
+	noIndex := preview || (page.SEO != nil && page.SEO.NoIndex)
+	if noIndex && !preview {
+		c.Header("X-Robots-Tag", "noindex")
+	}
+
+	format := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML)
+	switch c.Query("format") {
+	case "html":
+		format = gin.MIMEHTML
+	case "json":
+		format = gin.MIMEJSON
+	}
+	if format != gin.MIMEHTML {
+		body := gin.H{
+			"slug":          site.Slug,
+			"content":       content,
+			"page":          page,
+			"navigation":    nav,
+			"locale":        locale,
+			"defaultLocale": def,
+			"locales":       all,
+			"rtl":           rtl,
+			"seo":           publicSEO(site.Settings),
+			"updated":       lastModified(site),
+		}
+		if preview {
+			body["preview"] = true
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to encode site")
+			return
+		}
+		h.writeSite(c, site, gin.MIMEJSON+"; charset=utf-8", data, preview)
+		return
+	}
+
+	siteSettings := models.SiteSettings{}
+	if site.Settings != nil {
+		siteSettings = *site.Settings
+	}
+	view := render.Page{
+		Title:       pageTitle(site, page),
+		Description: siteSettings.Description,
+		Image:       siteSettings.OGImage,
+		Favicon:     siteSettings.Favicon,
+		Lang:        locale,
+		RTL:         rtl,
+		NoIndex:     noIndex,
+		Nav:         navLinks(doc, nav, page.ID, base, previewToken),
+		Sections:    render.Sections(page.Sections),
+	}
+	if page.SEO != nil && page.SEO.Description != "" {
+		view.Description = page.SEO.Description
+	}
+	if page.SEO != nil && page.SEO.Image != "" {
+		view.Image = page.SEO.Image
+	}
+	var buf bytes.Buffer
+	if err := render.Render(&buf, view); err != nil {
+		log.Printf("public: render site %s: %v", site.Slug, err)
+		respondError(c, http.StatusInternalServerError, "failed to render site")
+		return
+	}
+	h.writeSite(c, site, "text/html; charset=utf-8", buf.Bytes(), preview)
+}
+
+// writeSite sends a rendered page. Published pages carry validators so
+// clients and proxies can revalidate them with a 304.
+func (h *PublicHandler) writeSite(c *gin.Context, site models.Site, contentType string, body []byte, preview bool) {
+	if preview {
+		c.Data(http.StatusOK, contentType, body)
+		return
+	}
+	serveCacheable(c, contentType, h.CacheControl, lastModified(site), body)
+}
+
+// pageTitle is the page's SEO title, or its title followed by the site
+// title. The site title defaults to the site name.
+func pageTitle(site models.Site, page pages.Page) string {
+	siteTitle := site.Name
+	if site.Settings != nil && site.Settings.Title != "" {
+		siteTitle = site.Settings.Title
+	}
+	switch {
+	case page.SEO != nil && page.SEO.Title != "":
+		return page.SEO.Title
+	case page.Title != "" && page.Path != pages.HomePath:
+		return page.Title + " | " + siteTitle
+	}
+	return siteTitle
+}
+
+// publicSEO returns the settings that are safe to publish.
+func publicSEO(s *models.SiteSettings) gin.H {
+	if s == nil {
+		s = &models.SiteSettings{}
+	}
+	return gin.H{"title": s.Title, "description": s.Description, "ogImage": s.OGImage, "favicon": s.Favicon}
+}
+
+// navLinks resolves menu entries against the pages of doc. Entries pointing
+// at missing pages are dropped.
+func navLinks(doc pages.Document, items []pages.NavItem, currentID, base, previewToken string) []render.NavLink {
+	var out []render.NavLink
+	for _, item := range items {
+		link := render.NavLink{Label: item.Label, Href: item.URL}
+		if item.PageID != "" {
+			target, ok := doc.ByID(item.PageID)
+			if !ok {
+				continue
+			}
+			link.Href = pageHref(base, target.Path)
+			if previewToken != "" {
+				link.Href += "?preview=" + url.QueryEscape(previewToken)
+			}
+			link.Active = target.ID == currentID
+		}
+		link.Children = navLinks(doc, item.Children, currentID, base, previewToken)
+		out = append(out, link)
+	}
+	return out
+}
+
+// pageHref is the site-relative URL of the page at path.
+func pageHref(base, path string) string {
+	if path == pages.HomePath {
+		if base == "" {
+			return pages.HomePath
+		}
+		return base
+	}
+	return base + path
+}
+
+// redirectRenamed answers with a permanent redirect when slug is a retired
+// slug of a site that still exists. It reports whether it responded.
+func (h *PublicHandler) redirectRenamed(c *gin.Context, slug, path string) bool {
+	var redirect struct {
+		SiteID primitive.ObjectID `bson:"siteId"`
+	}
+	if err := h.SlugRedirects.FindOne(c, bson.M{"slug": slug}).Decode(&redirect); err != nil {
+		return false
+	}
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": redirect.SiteID, "deletedAt": nil}, options.FindOne().SetProjection(bson.M{"slug": 1})).Decode(&site); err != nil {
+		return false
+	}
+	target := pageHref("/s/"+site.Slug, pages.CleanPath(path))
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, target)
+	return true
 }
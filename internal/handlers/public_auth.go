@@ -10,8 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/slugs"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
@@ -21,12 +21,15 @@ type PublicAuthHandler struct {
 	Users           *mongo.Collection
 	Sites           *mongo.Collection
 	SitePermissions *mongo.Collection
+	SlugRedirects   *mongo.Collection
+	Templates       *mongo.Collection
 	Cfg             *config.Config
 }
 
 type registerRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	TemplateID string `json:"templateId"`
 }
 
 func (h *PublicAuthHandler) Register(c *gin.Context) {
@@ -46,6 +49,16 @@ func (h *PublicAuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	content, err := starterContent(c, h.Templates, req.TemplateID)
+	if err == errTemplateNotFound {
+		respondError(c, http.StatusBadRequest, "template not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load template")
+		return
+	}
+
 	now := time.Now().UTC()
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -77,24 +90,20 @@ func (h *PublicAuthHandler) Register(c *gin.Context) {
 		baseSlug = "site"
 	}
 
-	siteSlug, err := h.resolveUniqueSlug(c, baseSlug)
+	siteSlug, err := slugs.ResolveUnique(c, h.Sites, h.SlugRedirects, baseSlug)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "failed to create site")
 		return
 	}
 
 	site := models.Site{
-		Name:   fmt.Sprintf("%s Site", titleize(baseName)),
-		Slug:   siteSlug,
-		Status: "draft",
-		Content: map[string]interface{}{
-			"sections": []map[string]interface{}{
-				{"type": "hero", "data": map[string]interface{}{"title": "Hoş geldin", "subtitle": "Siten hazır"}},
-				{"type": "cta", "data": map[string]interface{}{"title": "İletişim", "buttonText": "Teklif Al", "buttonHref": "#contact"}},
-			},
-		},
-		CreatedAt: now,
-		UpdatedAt: now,
+		Name:                  fmt.Sprintf("%s Site", titleize(baseName)),
+		Slug:                  siteSlug,
+		Status:                "draft",
+		Content:               content,
+		HasUnpublishedChanges: true,
+		CreatedAt:             now,
+		UpdatedAt:             now,
 	}
 
 	siteResult, err := h.Sites.InsertOne(c, site)
@@ -128,23 +137,6 @@ func (h *PublicAuthHandler) Register(c *gin.Context) {
 	})
 }
 
-func (h *PublicAuthHandler) resolveUniqueSlug(c *gin.Context, baseSlug string) (string, error) {
-	for i := 0; i <= 200; i++ {
-		slug := baseSlug
-		if i > 0 {
-			slug = fmt.Sprintf("%s-%d", baseSlug, i)
-		}
-		count, err := h.Sites.CountDocuments(c, bson.M{"slug": slug})
-		if err != nil {
-			return "", err
-		}
-		if count == 0 {
-			return slug, nil
-		}
-	}
-	return "", fmt.Errorf("unable to resolve unique slug")
-}
-
 func titleize(value string) string {
 	clean := strings.TrimSpace(value)
 	if clean == "" {
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SubmitForm accepts a submission to a form of a published site.
+func (h *PublicHandler) SubmitForm(c *gin.Context) {
+	slug := c.Param("slug")
+	h.submitForm(c, "slug:"+slug, bson.M{"slug": slug}, c.Param("formId"))
+}
+
+// submitForm stores a submission sent as JSON or as an urlencoded or
+// multipart HTML form. Submissions are rate limited per visitor IP and site.
+// A filled-in honeypot gets the same answer as a real submission but is
+// dropped.
+func (h *PublicHandler) submitForm(c *gin.Context, cacheKey string, filter bson.M, rawFormID string) {
+	formID, err := primitive.ObjectIDFromHex(rawFormID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "form not found")
+		return
+	}
+	site, err := h.publishedSite(c, cacheKey, filter)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch site")
+		return
+	}
+	var form models.Form
+	if err := h.Forms.FindOne(c, bson.M{"_id": formID, "siteId": site.ID}).Decode(&form); err != nil {
+		respondError(c, http.StatusNotFound, "form not found")
+		return
+	}
+
+	ipHash := utils.HashToken(c.ClientIP())
+	allowed, retryAfter, err := h.FormLimiter.Allow(c, site.ID.Hex()+":"+ipHash)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check rate limit")
+		return
+	}
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondError(c, http.StatusTooManyRequests, "too many submissions")
+		return
+	}
+
+	values, err := readSubmission(c)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(c, http.StatusRequestEntityTooLarge, "submission too large")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "invalid submission")
+		return
+	}
+	if values[forms.HoneypotField] != "" {
+		c.JSON(http.StatusCreated, gin.H{"status": "received"})
+		return
+	}
+	data, errs := forms.Clean(form, values)
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid submission", "details": errs})
+		return
+	}
+
+	now := time.Now().UTC()
+	submission := models.FormSubmission{
+		SiteID:    site.ID,
+		FormID:    form.ID,
+		FormName:  form.Name,
+		Data:      data,
+		IPHash:    ipHash,
+		UserAgent: truncateRunes(c.Request.UserAgent(), 300),
+		CreatedAt: now,
+	}
+	if to := notifyAddress(site, form); h.NotifyForms && to != "" {
+		submission.Notification = &models.FormNotification{To: to, Status: forms.NotifyPending, NextAttemptAt: &now}
+	}
+	if _, err := h.Submissions.InsertOne(c, submission); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to store submission")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "received"})
+}
+
+// readSubmission returns the submitted values. Only the first value of a
+// repeated form field counts; JSON numbers and booleans become strings.
+func readSubmission(c *gin.Context) (map[string]string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, forms.MaxBodyBytes)
+	values := map[string]string{}
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		var raw map[string]interface{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&raw); err != nil {
+			return nil, err
+		}
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				values[key] = v
+			case bool:
+				values[key] = strconv.FormatBool(v)
+			case float64:
+				values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return values, nil
+	case binding.MIMEMultipartPOSTForm:
+		if err := c.Request.ParseMultipartForm(forms.MaxBodyBytes); err != nil {
+			return nil, err
+		}
+	default:
+		if err := c.Request.ParseForm(); err != nil {
+			return nil, err
+		}
+	}
+	for key, list := range c.Request.PostForm {
+		if len(list) > 0 {
+			values[key] = list[0]
+		}
+	}
+	return values, nil
+}
+
+// customDomainFormID reports whether a request to a custom domain submits a
+// form, which it does as POST /forms/:formId.
+func customDomainFormID(method, path string) (string, bool) {
+	if method != http.MethodPost {
+		return "", false
+	}
+	id, ok := strings.CutPrefix(path, "/forms/")
+	return id, ok && id != "" && !strings.Contains(id, "/")
+}
+
+// notifyAddress is where submissions to form are emailed.
+func notifyAddress(site models.Site, form models.Form) string {
+	if form.NotifyEmail != "" {
+		return form.NotifyEmail
+	}
+	if site.Settings != nil {
+		return site.Settings.ContactEmail
+	}
+	return ""
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/seo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	seoSitemap = "sitemap.xml"
+	seoRobots  = "robots.txt"
+	seoFeed    = "feed.xml"
+
+	seoCacheControl = "public, max-age=3600"
+)
+
+// seoFileName reports whether path names one of the crawler files.
+func seoFileName(path string) (string, bool) {
+	switch path {
+	case "/" + seoSitemap, "/" + seoRobots, "/" + seoFeed:
+		return path[1:], true
+	}
+	return "", false
+}
+
+// serveSEOFile generates one of the crawler files from the published
+// snapshot, loaded like the pages themselves so that the draft is never read.
+// They are derived data, so publishing regenerates them and the ETag changes
+// with their bytes. hostname is set when the request came in through a
+// custom domain.
+func (h *PublicHandler) serveSEOFile(c *gin.Context, cacheKey string, filter bson.M, hostname, name string) {
+	site, err := h.publishedSite(c, cacheKey, filter)
+	if err == mongo.ErrNoDocuments {
+		// Unpublished and archived sites are kept out of search results.
+		if name == seoRobots && h.siteExists(c, filter) {
+			serveCacheable(c, "text/plain; charset=utf-8", seoCacheControl, time.Time{}, seo.Robots(false, ""))
+			return
+		}
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch site")
+		return
+	}
+	home, prefix := h.siteURLs(c, site, hostname)
+
+	var body []byte
+	var contentType string
+	switch name {
+	case seoRobots:
+		body = seo.Robots(true, prefix+"/"+seoSitemap)
+		contentType = "text/plain; charset=utf-8"
+	case seoSitemap, seoFeed:
+		pageURL := func(path string) string {
+			if path == pages.HomePath {
+				return home
+			}
+			return prefix + path
+		}
+		doc, err := pages.Parse(site.PublishedContent)
+		if err != nil {
+			log.Printf("public: parse pages of %s: %v", site.Slug, err)
+			respondError(c, http.StatusInternalServerError, "failed to generate "+name)
+			return
+		}
+		if name == seoSitemap {
+			// Translated pages are listed under their locale prefix.
+			def, all := siteLocales(site)
+			var urls []seo.URL
+			for _, locale := range all {
+				for _, page := range doc.Ordered() {
+					page = page.Localized(locale)
+					if page.SEO != nil && page.SEO.NoIndex {
+						continue
+					}
+					loc := pageURL(page.Path)
+					if locale != def {
+						loc = prefix + pageHref("/"+locale, page.Path)
+					}
+					urls = append(urls, seo.URL{Loc: loc, LastMod: publishedTime(site)})
+				}
+			}
+			body, err = seo.Sitemap(urls)
+			contentType = "application/xml; charset=utf-8"
+		} else {
+			channel := seo.Channel{Title: site.Name, Link: home, FeedURL: prefix + "/" + seoFeed, Updated: publishedTime(site)}
+			body, err = seo.Feed(channel, seo.Posts(doc, pageURL))
+			contentType = "application/rss+xml; charset=utf-8"
+		}
+		if err != nil {
+			log.Printf("public: generate %s for %s: %v", name, site.Slug, err)
+			respondError(c, http.StatusInternalServerError, "failed to generate "+name)
+			return
+		}
+	}
+
+	serveCacheable(c, contentType, seoCacheControl, lastModified(site), body)
+}
+
+// serveCacheable sends body with an ETag derived from its bytes.
+// ServeContent answers If-None-Match and If-Modified-Since with 304.
+func serveCacheable(c *gin.Context, contentType, cacheControl string, modified time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+	c.Header("Content-Type", contentType)
+	if cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(body))
+}
+
+// siteURLs returns the absolute home page URL of site and the prefix that
+// site-relative paths are appended to. A verified custom domain is the
+// canonical address when there is one.
+func (h *PublicHandler) siteURLs(c *gin.Context, site models.Site, hostname string) (string, string) {
+	if hostname != "" {
+		origin := requestScheme(c) + "://" + hostname
+		return origin + "/", origin
+	}
+	if domain := h.verifiedDomain(c, site.ID); domain != "" {
+		return "https://" + domain + "/", "https://" + domain
+	}
+	origin := h.BaseURL
+	if origin == "" {
+		origin = requestScheme(c) + "://" + c.Request.Host
+	}
+	home := origin + "/s/" + site.Slug
+	return home, home
+}
+
+func (h *PublicHandler) verifiedDomain(c *gin.Context, siteID primitive.ObjectID) string {
+	var domain models.SiteDomain
+	if err := h.Domains.FindOne(c, bson.M{"siteId": siteID, "status": domains.StatusVerified}).Decode(&domain); err != nil {
+		return ""
+	}
+	return domain.Hostname
+}
+
+func requestScheme(c *gin.Context) string {
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
+
+// siteExists reports whether a site that is not deleted matches filter.
+func (h *PublicHandler) siteExists(c *gin.Context, filter bson.M) bool {
+	err := h.Sites.FindOne(c,
+		bson.M{"$and": []bson.M{filter, {"deletedAt": nil}}},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Err()
+	return err == nil
+}
+
+// publishedTime is when the published snapshot was last replaced. It is zero
+// for sites published before that was recorded: draft saves never count.
+func publishedTime(site models.Site) time.Time {
+	if site.PublishedAt != nil {
+		return *site.PublishedAt
+	}
+	return time.Time{}
+}
+
+// lastModified is when the public output of a site last changed: its last
+// publish, or a later change to the settings, locales or slug, which are
+// served live rather than from the published snapshot.
+func lastModified(site models.Site) time.Time {
+	modified := publishedTime(site)
+	if site.SettingsUpdatedAt != nil && site.SettingsUpdatedAt.After(modified) {
+		modified = *site.SettingsUpdatedAt
+	}
+	return modified
+}
@@ -1,7 +1,16 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
 
 func respondError(c *gin.Context, status int, message string) {
 	c.JSON(status, gin.H{"error": message})
 }
+
+func respondInvalidContent(c *gin.Context, errs []sections.ValidationError) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid content", "details": errs})
+}
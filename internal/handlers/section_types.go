@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
+
+type SectionTypeHandler struct{}
+
+func (h *SectionTypeHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, sections.All())
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/settings"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (h *SiteHandler) GetSettings(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"settings": 1})).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if site.Settings == nil {
+		site.Settings = &models.SiteSettings{}
+	}
+	c.JSON(http.StatusOK, site.Settings)
+}
+
+// UpdateSettings replaces the site settings. Omitted fields are cleared.
+// Settings are not part of the draft, so they are live immediately.
+func (h *SiteHandler) UpdateSettings(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+
+	var req models.SiteSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	req = settings.Normalize(req)
+	if errs := settings.Validate(req); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid settings", "details": errs})
+		return
+	}
+
+	now := time.Now().UTC()
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$set": bson.M{"settings": req, "updatedAt": now, "settingsUpdatedAt": now}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update settings")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	userID, _ := getUserID(c)
+	h.Events.Publish(c, siteID, events.SettingsUpdated, map[string]interface{}{"userId": userID.Hex()})
+	c.JSON(http.StatusOK, req)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type trashedSite struct {
+	models.Site `bson:",inline"`
+	PurgeAt     time.Time `json:"purgeAt"`
+}
+
+// RequireSite rejects requests for sites that do not exist or are in the trash.
+func (h *SiteHandler) RequireSite(c *gin.Context) {
+	h.requireSite(c, false)
+}
+
+// RequireWritableSite additionally rejects archived sites, which are read-only.
+func (h *SiteHandler) RequireWritableSite(c *gin.Context) {
+	h.requireSite(c, true)
+}
+
+func (h *SiteHandler) requireSite(c *gin.Context, writable bool) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid site id"})
+		return
+	}
+	var site models.Site
+	err = h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"archivedAt": 1, "deletedAt": 1})).Decode(&site)
+	if err == mongo.ErrNoDocuments || (err == nil && site.DeletedAt != nil) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "site not found"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch site"})
+		return
+	}
+	if writable && site.ArchivedAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "site is archived"})
+		return
+	}
+	c.Next()
+}
+
+// Archive takes the site offline and makes it read-only. Pending schedules
+// are dropped.
+func (h *SiteHandler) Archive(c *gin.Context) {
+	h.retire(c, "archivedAt", "archived")
+}
+
+// Delete moves the site to the trash. It can be restored until the trash
+// retention window ends, after which the purge job removes it for good.
+func (h *SiteHandler) Delete(c *gin.Context) {
+	h.retire(c, "deletedAt", "deleted")
+}
+
+func (h *SiteHandler) retire(c *gin.Context, field, status string) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+
+	// Retiring twice would move the timestamp, and with it the purge date.
+	// RequireSite has already answered 404 for missing sites.
+	filter := bson.M{"_id": siteID, "deletedAt": nil}
+	if field == "archivedAt" {
+		filter["archivedAt"] = nil
+	}
+	now := time.Now().UTC()
+	var before models.Site
+	err := h.Sites.FindOneAndUpdate(c, filter,
+		bson.M{
+			"$set":   bson.M{field: now, "status": "draft", "publishedAt": nil, "updatedAt": now},
+			"$unset": bson.M{"publishAt": "", "unpublishAt": ""},
+		},
+		options.FindOneAndUpdate().SetProjection(bson.M{"status": 1}),
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusConflict, "site is already "+status)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update site")
+		return
+	}
+	if before.Status == "published" {
+		h.Events.Publish(c, siteID, events.SiteUnpublished, map[string]interface{}{"reason": status})
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
+
+func (h *SiteHandler) Unarchive(c *gin.Context) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID, "archivedAt": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"archivedAt": ""}, "$set": bson.M{"updatedAt": time.Now().UTC()}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update site")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusConflict, "site is not archived")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "unarchived"})
+}
+
+// Restore takes a site out of the trash while it is still within the
+// retention window.
+func (h *SiteHandler) Restore(c *gin.Context) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	result, err := h.Sites.UpdateOne(c,
+		bson.M{"_id": siteID, "deletedAt": bson.M{"$gt": now.Add(-h.TrashRetention)}},
+		bson.M{"$unset": bson.M{"deletedAt": ""}, "$set": bson.M{"updatedAt": now}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to restore site")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "site is not in the trash")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "restored"})
+}
+
+// ListTrash returns deleted sites the caller owns, with the time each will be
+// purged. Superadmins see the whole trash.
+func (h *SiteHandler) ListTrash(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	globalRole, _ := getGlobalRole(c)
+
+	filter := bson.M{"deletedAt": bson.M{"$ne": nil}}
+	if globalRole != "superadmin" {
+		siteIDs, err := h.getOwnedSiteIDs(c, userID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to fetch permissions")
+			return
+		}
+		filter["_id"] = bson.M{"$in": siteIDs}
+	}
+
+	cursor, err := h.Sites.Find(c, filter, options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch sites")
+		return
+	}
+	defer cursor.Close(c)
+	trash := []trashedSite{}
+	for cursor.Next(c) {
+		var site models.Site
+		if err := cursor.Decode(&site); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to decode sites")
+			return
+		}
+		trash = append(trash, trashedSite{Site: site, PurgeAt: site.DeletedAt.Add(h.TrashRetention)})
+	}
+	c.JSON(http.StatusOK, trash)
+}
+
+// requireOwner parses the site id and checks that the caller owns the site.
+func (h *SiteHandler) requireOwner(c *gin.Context) (primitive.ObjectID, bool) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return primitive.NilObjectID, false
+	}
+	role, err := h.currentSiteRole(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return primitive.NilObjectID, false
+	}
+	if role != "superadmin" && role != "owner" {
+		respondError(c, http.StatusForbidden, "owner access required")
+		return primitive.NilObjectID, false
+	}
+	return siteID, true
+}
+
+func (h *SiteHandler) getOwnedSiteIDs(c *gin.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := h.SitePermissions.Find(c, bson.M{"userId": userID, "role": "owner"})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+	var perms []models.SitePermission
+	if err := cursor.All(c, &perms); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(perms))
+	for _, p := range perms {
+		ids = append(ids, p.SiteID)
+	}
+	return ids, nil
+}
+
+// requireAccess parses the site id and checks that the caller can read the
+// site, or write to it when write is set.
+func (h *SiteHandler) requireAccess(c *gin.Context, write bool) (primitive.ObjectID, bool) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return primitive.NilObjectID, false
+	}
+	var allowed bool
+	if write {
+		allowed, err = h.canWriteCurrentUser(c, siteID)
+	} else {
+		allowed, err = h.canReadCurrentUser(c, siteID)
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return primitive.NilObjectID, false
+	}
+	if !allowed {
+		if write {
+			respondError(c, http.StatusForbidden, "write access required")
+		} else {
+			respondError(c, http.StatusForbidden, "no access to site")
+		}
+		return primitive.NilObjectID, false
+	}
+	return siteID, true
+}
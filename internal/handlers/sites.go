@@ -2,24 +2,55 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/publishing"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/slugs"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SiteHandler struct {
 	Sites           *mongo.Collection
 	SitePermissions *mongo.Collection
+	SiteReviews     *mongo.Collection
+	SlugRedirects   *mongo.Collection
+	Templates       *mongo.Collection
+	Locks           *mongo.Collection
+	Events          *events.Bus
+	TrashRetention  time.Duration
 }
 
 type createSiteRequest struct {
-	Name string `json:"name" binding:"required"`
-	Slug string `json:"slug" binding:"required"`
+	Name       string `json:"name" binding:"required"`
+	Slug       string `json:"slug" binding:"required"`
+	TemplateID string `json:"templateId"`
+}
+
+type cloneSiteRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type scheduleRequest struct {
+	At time.Time `json:"at" binding:"required"`
+}
+
+type updateWorkflowRequest struct {
+	Enabled   bool     `json:"enabled"`
+	Approvers []string `json:"approvers"`
+}
+
+type reviewRequest struct {
+	Comment string `json:"comment"`
 }
 
 type updateContentRequest struct {
@@ -34,7 +65,7 @@ func (h *SiteHandler) List(c *gin.Context) {
 	}
 	globalRole, _ := getGlobalRole(c)
 
-	filter := bson.M{}
+	filter := bson.M{"deletedAt": nil}
 	if globalRole != "superadmin" {
 		siteIDs, err := h.getPermittedSiteIDs(c, userID)
 		if err != nil {
@@ -45,7 +76,7 @@ func (h *SiteHandler) List(c *gin.Context) {
 			c.JSON(http.StatusOK, []models.Site{})
 			return
 		}
-		filter = bson.M{"_id": bson.M{"$in": siteIDs}}
+		filter["_id"] = bson.M{"$in": siteIDs}
 	}
 
 	cursor, err := h.Sites.Find(c, filter)
@@ -81,9 +112,31 @@ func (h *SiteHandler) Create(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "invalid slug")
 		return
 	}
+	reserved, err := slugs.Reserved(c, h.SlugRedirects, slug, primitive.NilObjectID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check slug")
+		return
+	}
+	if reserved {
+		respondError(c, http.StatusConflict, "slug already exists")
+		return
+	}
+
+	content := map[string]interface{}{}
+	if req.TemplateID != "" {
+		content, err = starterContent(c, h.Templates, req.TemplateID)
+		if err == errTemplateNotFound {
+			respondError(c, http.StatusBadRequest, "template not found")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to load template")
+			return
+		}
+	}
 
 	now := time.Now().UTC()
-	site := models.Site{Name: req.Name, Slug: slug, Status: "draft", Content: map[string]interface{}{}, CreatedAt: now, UpdatedAt: now}
+	site := models.Site{Name: req.Name, Slug: slug, Status: "draft", Content: content, HasUnpublishedChanges: len(content) > 0, CreatedAt: now, UpdatedAt: now}
 	result, err := h.Sites.InsertOne(c, site)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
@@ -97,6 +150,81 @@ func (h *SiteHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, site)
 }
 
+// Clone copies the draft content of a site into a new draft site owned by the
+// caller. Without a slug, one is derived from the source slug.
+func (h *SiteHandler) Clone(c *gin.Context) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	var req cloneSiteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request")
+			return
+		}
+	}
+
+	var source models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&source); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+
+	var slug string
+	var err error
+	if strings.TrimSpace(req.Slug) != "" {
+		slug = utils.NormalizeSlug(req.Slug)
+		if !utils.IsValidSlug(slug) {
+			respondError(c, http.StatusBadRequest, "invalid slug")
+			return
+		}
+		reserved, err := slugs.Reserved(c, h.SlugRedirects, slug, primitive.NilObjectID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to check slug")
+			return
+		}
+		if reserved {
+			respondError(c, http.StatusConflict, "slug already exists")
+			return
+		}
+	} else {
+		slug, err = slugs.ResolveUnique(c, h.Sites, h.SlugRedirects, source.Slug+"-copy")
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to resolve slug")
+			return
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+
+	now := time.Now().UTC()
+	clone := models.Site{Name: name, Slug: slug, Status: "draft", Content: source.Content, Locales: source.Locales, Settings: source.Settings, HasUnpublishedChanges: true, CreatedAt: now, UpdatedAt: now}
+	if clone.Content == nil {
+		clone.Content = map[string]interface{}{}
+	}
+	result, err := h.Sites.InsertOne(c, clone)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(c, http.StatusConflict, "slug already exists")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "failed to clone site")
+		return
+	}
+	clone.ID = result.InsertedID.(primitive.ObjectID)
+
+	userID, _ := getUserID(c)
+	permission := models.SitePermission{SiteID: clone.ID, UserID: userID, Role: "owner", CreatedAt: now, UpdatedAt: now}
+	if _, err := h.SitePermissions.InsertOne(c, permission); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create site permission")
+		return
+	}
+	c.JSON(http.StatusCreated, clone)
+}
+
 func (h *SiteHandler) Get(c *gin.Context) {
 	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
@@ -118,6 +246,7 @@ func (h *SiteHandler) Get(c *gin.Context) {
 		respondError(c, http.StatusNotFound, "site not found")
 		return
 	}
+	c.Header("ETag", siteETag(site.Version))
 	c.JSON(http.StatusOK, site)
 }
 
@@ -142,17 +271,102 @@ func (h *SiteHandler) UpdateContent(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "invalid request")
 		return
 	}
+	req.Content = pages.Normalize(req.Content)
+	if errs := pages.ValidateContent(req.Content); len(errs) > 0 {
+		respondInvalidContent(c, errs)
+		return
+	}
 
-	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$set": bson.M{"content": req.Content, "updatedAt": time.Now().UTC()}})
+	expected, conditional, err := parseIfMatch(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := bson.M{"_id": siteID}
+	if conditional {
+		filter["version"] = expected
+	}
+	if !h.checkLocks(c, siteID, nil, req.Content) {
+		return
+	}
+
+	version, err := h.saveContent(c, filter, req.Content)
+	if err == mongo.ErrNoDocuments {
+		h.respondWriteMiss(c, siteID, conditional)
+		return
+	}
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "failed to update content")
 		return
 	}
-	if result.MatchedCount == 0 {
+	h.publishContentUpdated(c, siteID, version)
+	c.Header("ETag", siteETag(version))
+	c.JSON(http.StatusOK, gin.H{"status": "updated", "version": version})
+}
+
+// saveContent replaces the draft of the site matching filter and returns the
+// new version. mongo.ErrNoDocuments means the filter did not match.
+func (h *SiteHandler) saveContent(c *gin.Context, filter bson.M, content map[string]interface{}) (int64, error) {
+	var site models.Site
+	err := h.Sites.FindOneAndUpdate(c, filter,
+		bson.M{"$set": bson.M{"content": content, "hasUnpublishedChanges": true, "updatedAt": time.Now().UTC()}, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"version": 1}),
+	).Decode(&site)
+	return site.Version, err
+}
+
+// checkLocks responds 423 and returns false when writing next would touch
+// content locked by another user. current is the stored content when the
+// caller already has it, otherwise it is loaded on demand.
+func (h *SiteHandler) checkLocks(c *gin.Context, siteID primitive.ObjectID, current map[string]interface{}, next map[string]interface{}) bool {
+	userID, _ := getUserID(c)
+	load := func() (map[string]interface{}, error) {
+		if current != nil {
+			return current, nil
+		}
+		var site models.Site
+		err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"content": 1})).Decode(&site)
+		return site.Content, err
+	}
+	violated, err := lockedSectionChanges(c, h.Locks, siteID, userID, load, next)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "site not found")
+		return false
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check locks")
+		return false
+	}
+	if len(violated) > 0 {
+		respondLocked(c, violated)
+		return false
+	}
+	return true
+}
+
+func (h *SiteHandler) publishContentUpdated(c *gin.Context, siteID primitive.ObjectID, version int64) {
+	userID, _ := getUserID(c)
+	h.Events.Publish(c, siteID, events.ContentUpdated, map[string]interface{}{"version": version, "userId": userID.Hex()})
+}
+
+// respondWriteMiss explains why a conditional write matched nothing: either
+// the site is gone or its version moved on.
+func (h *SiteHandler) respondWriteMiss(c *gin.Context, siteID primitive.ObjectID, conditional bool) {
+	var current models.Site
+	err := h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"version": 1})).Decode(&current)
+	if err == mongo.ErrNoDocuments {
 		respondError(c, http.StatusNotFound, "site not found")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update content")
+		return
+	}
+	if conditional {
+		respondVersionConflict(c, current.Version)
+		return
+	}
+	respondError(c, http.StatusInternalServerError, "failed to update content")
 }
 
 func (h *SiteHandler) Publish(c *gin.Context)   { h.togglePublish(c, true) }
@@ -176,24 +390,390 @@ func (h *SiteHandler) togglePublish(c *gin.Context, publish bool) {
 
 	now := time.Now().UTC()
 	status := "draft"
-	var publishedAt *time.Time
+	var result *mongo.UpdateResult
 	if publish {
 		status = "published"
-		publishedAt = &now
+		result, err = publishing.Publish(c, h.Sites, bson.M{"_id": siteID}, now)
+	} else {
+		result, err = publishing.Unpublish(c, h.Sites, bson.M{"_id": siteID}, now)
 	}
-
-	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$set": bson.M{"status": status, "publishedAt": publishedAt, "updatedAt": now}})
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "failed to update status")
 		return
 	}
 	if result.MatchedCount == 0 {
-		respondError(c, http.StatusNotFound, "site not found")
+		var site models.Site
+		if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&site); err != nil {
+			respondError(c, http.StatusNotFound, "site not found")
+			return
+		}
+		respondError(c, http.StatusConflict, "current content must be approved before publishing")
 		return
 	}
+	eventType := events.SiteUnpublished
+	if publish {
+		eventType = events.SitePublished
+	}
+	h.Events.Publish(c, siteID, eventType, nil)
 	c.JSON(http.StatusOK, gin.H{"status": status})
 }
 
+// SubmitForReview asks the site's approvers to review the current version.
+func (h *SiteHandler) SubmitForReview(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+	h.transitionReview(c, siteID, "submitted", "in_review", func(site *models.Site) (int, string) {
+		return 0, ""
+	})
+}
+
+func (h *SiteHandler) ApproveReview(c *gin.Context) { h.decideReview(c, "approved", "approved") }
+func (h *SiteHandler) RejectReview(c *gin.Context) {
+	h.decideReview(c, "changes_requested", "changes_requested")
+}
+
+func (h *SiteHandler) decideReview(c *gin.Context, action, state string) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	userID, err := getUserID(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	role, err := h.currentSiteRole(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if role == "" {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+	h.transitionReview(c, siteID, action, state, func(site *models.Site) (int, string) {
+		if role != "superadmin" && role != "owner" && !containsObjectID(site.Workflow.Approvers, userID) {
+			return http.StatusForbidden, "only owners and designated approvers can review"
+		}
+		if site.Workflow.State != "in_review" {
+			return http.StatusConflict, "site is not in review"
+		}
+		if site.Workflow.SubmittedVersion != site.Version {
+			return http.StatusConflict, "content changed since it was submitted"
+		}
+		return 0, ""
+	})
+}
+
+// transitionReview moves the workflow to state after check accepts the loaded
+// site, and records the transition in the review history. check returns a
+// non-zero status to refuse. The update is conditional on the version and
+// state that were checked.
+func (h *SiteHandler) transitionReview(c *gin.Context, siteID primitive.ObjectID, action, state string, check func(site *models.Site) (int, string)) {
+	var req reviewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request")
+			return
+		}
+	}
+	comment := strings.TrimSpace(req.Comment)
+	if state == "changes_requested" && comment == "" {
+		respondError(c, http.StatusBadRequest, "comment is required when requesting changes")
+		return
+	}
+	userID, err := getUserID(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if site.Workflow == nil || !site.Workflow.Enabled {
+		respondError(c, http.StatusConflict, "review workflow is not enabled for this site")
+		return
+	}
+	if status, reason := check(&site); status != 0 {
+		respondError(c, status, reason)
+		return
+	}
+
+	set := bson.M{"workflow.state": state, "updatedAt": time.Now().UTC()}
+	switch state {
+	case "in_review":
+		set["workflow.submittedVersion"] = site.Version
+	case "approved":
+		set["workflow.approvedVersion"] = site.Version
+	}
+	var currentState interface{} = site.Workflow.State
+	if site.Workflow.State == "" {
+		currentState = bson.M{"$in": bson.A{"", nil}}
+	}
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID, "version": site.Version, "workflow.state": currentState}, bson.M{"$set": set})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update review state")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusConflict, "site changed during review, retry")
+		return
+	}
+
+	review := models.SiteReview{SiteID: siteID, UserID: userID, Action: action, State: state, Version: site.Version, Comment: comment, CreatedAt: time.Now().UTC()}
+	if _, err := h.SiteReviews.InsertOne(c, review); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to record review")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"state": state, "version": site.Version})
+}
+
+// UpdateWorkflow turns the review workflow on or off and sets the approvers.
+func (h *SiteHandler) UpdateWorkflow(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	role, err := h.currentSiteRole(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if role != "superadmin" && role != "owner" {
+		respondError(c, http.StatusForbidden, "owner access required")
+		return
+	}
+
+	var req updateWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	approvers := make([]primitive.ObjectID, 0, len(req.Approvers))
+	for _, raw := range req.Approvers {
+		approverID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid approver id")
+			return
+		}
+		member, err := h.canReadSite(c, approverID, siteID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to check approvers")
+			return
+		}
+		if !member {
+			respondError(c, http.StatusBadRequest, "approvers must be members of the site")
+			return
+		}
+		approvers = append(approvers, approverID)
+	}
+
+	set := bson.M{"workflow.enabled": req.Enabled, "workflow.approvers": approvers, "updatedAt": time.Now().UTC()}
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$set": set})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update workflow")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": req.Enabled, "approvers": approvers})
+}
+
+// ListReviews returns the review history of a site, newest first.
+func (h *SiteHandler) ListReviews(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canReadCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "no access to site")
+		return
+	}
+
+	cursor, err := h.SiteReviews.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch reviews")
+		return
+	}
+	defer cursor.Close(c)
+	reviews := []models.SiteReview{}
+	if err := cursor.All(c, &reviews); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode reviews")
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+func (h *SiteHandler) SchedulePublish(c *gin.Context)   { h.setSchedule(c, "publishAt") }
+func (h *SiteHandler) ScheduleUnpublish(c *gin.Context) { h.setSchedule(c, "unpublishAt") }
+func (h *SiteHandler) CancelPublish(c *gin.Context)     { h.cancelSchedule(c, "publishAt") }
+func (h *SiteHandler) CancelUnpublish(c *gin.Context)   { h.cancelSchedule(c, "unpublishAt") }
+
+func (h *SiteHandler) setSchedule(c *gin.Context, field string) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+
+	var req scheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	now := time.Now().UTC()
+	at := req.At.UTC()
+	if !at.After(now) {
+		respondError(c, http.StatusBadRequest, "scheduled time must be in the future")
+		return
+	}
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if field == "publishAt" && site.UnpublishAt != nil && !site.UnpublishAt.After(at) {
+		respondError(c, http.StatusBadRequest, "publishAt must be before unpublishAt")
+		return
+	}
+	if field == "unpublishAt" && site.PublishAt != nil && !at.After(*site.PublishAt) {
+		respondError(c, http.StatusBadRequest, "unpublishAt must be after publishAt")
+		return
+	}
+
+	update := bson.M{"$set": bson.M{field: at, "updatedAt": now}}
+	if field == "publishAt" {
+		update["$unset"] = bson.M{"publishMissed": ""}
+	}
+	if _, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, update); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to schedule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{field: at})
+}
+
+func (h *SiteHandler) cancelSchedule(c *gin.Context, field string) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID}, bson.M{"$unset": bson.M{field: ""}, "$set": bson.M{"updatedAt": time.Now().UTC()}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to cancel schedule")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancelled"})
+}
+
+// DiscardDraft resets the draft content to the last published snapshot.
+func (h *SiteHandler) DiscardDraft(c *gin.Context) {
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid site id")
+		return
+	}
+	allowed, err := h.canWriteCurrentUser(c, siteID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check permission")
+		return
+	}
+	if !allowed {
+		respondError(c, http.StatusForbidden, "write access required")
+		return
+	}
+
+	var site models.Site
+	err = h.Sites.FindOne(c, bson.M{"_id": siteID}, options.FindOne().SetProjection(bson.M{"content": 1, "publishedContent": 1, "version": 1})).Decode(&site)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to discard draft")
+		return
+	}
+	if site.PublishedContent == nil {
+		respondError(c, http.StatusConflict, "site has never been published")
+		return
+	}
+	// Discarding rewrites every section that differs from the snapshot, so
+	// it must respect other users' locks like any other edit.
+	if !h.checkLocks(c, siteID, site.Content, site.PublishedContent) {
+		return
+	}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"content":               "$publishedContent",
+			"hasUnpublishedChanges": false,
+			"version":               bson.M{"$add": bson.A{"$version", 1}},
+			"updatedAt":             time.Now().UTC(),
+		}}},
+	}
+	// The version guard keeps the lock check valid for the draft replaced.
+	result, err := h.Sites.UpdateOne(c, bson.M{"_id": siteID, "version": site.Version, "publishedContent": bson.M{"$type": "object"}}, update)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to discard draft")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusConflict, "site is being modified concurrently, retry")
+		return
+	}
+	h.publishContentUpdated(c, siteID, site.Version+1)
+	c.JSON(http.StatusOK, gin.H{"status": "discarded"})
+}
+
 func (h *SiteHandler) canReadCurrentUser(c *gin.Context, siteID primitive.ObjectID) (bool, error) {
 	userID, err := getUserID(c)
 	if err != nil {
@@ -218,6 +798,28 @@ func (h *SiteHandler) canWriteCurrentUser(c *gin.Context, siteID primitive.Objec
 	return h.canWriteSite(c, userID, siteID)
 }
 
+// currentSiteRole returns "superadmin" for superadmins, otherwise the caller's
+// role on the site, or "" without access.
+func (h *SiteHandler) currentSiteRole(c *gin.Context, siteID primitive.ObjectID) (string, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return "", err
+	}
+	role, _ := getGlobalRole(c)
+	if role == "superadmin" {
+		return role, nil
+	}
+	var perm models.SitePermission
+	err = h.SitePermissions.FindOne(c, bson.M{"userId": userID, "siteId": siteID}).Decode(&perm)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return perm.Role, nil
+}
+
 func (h *SiteHandler) canReadSite(c *gin.Context, userID, siteID primitive.ObjectID) (bool, error) {
 	count, err := h.SitePermissions.CountDocuments(c, bson.M{"userId": userID, "siteId": siteID, "role": bson.M{"$in": []string{"viewer", "editor", "owner"}}})
 	return count > 0, err
@@ -244,3 +846,12 @@ func (h *SiteHandler) getPermittedSiteIDs(c *gin.Context, userID primitive.Objec
 	}
 	return ids, nil
 }
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
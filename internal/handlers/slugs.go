@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/slugs"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type changeSlugRequest struct {
+	Slug string `json:"slug" binding:"required"`
+}
+
+// ChangeSlug renames the site's slug. The old slug is kept in the site's
+// history and reserved as a permanent redirect to the new one.
+func (h *SiteHandler) ChangeSlug(c *gin.Context) {
+	siteID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	var req changeSlugRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	slug := utils.NormalizeSlug(req.Slug)
+	if !utils.IsValidSlug(slug) {
+		respondError(c, http.StatusBadRequest, "invalid slug")
+		return
+	}
+
+	var site models.Site
+	if err := h.Sites.FindOne(c, bson.M{"_id": siteID}).Decode(&site); err != nil {
+		respondError(c, http.StatusNotFound, "site not found")
+		return
+	}
+	if site.Slug == slug {
+		c.JSON(http.StatusOK, gin.H{"slug": slug})
+		return
+	}
+	reserved, err := slugs.Reserved(c, h.SlugRedirects, slug, site.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to check slug")
+		return
+	}
+	if reserved {
+		respondError(c, http.StatusConflict, "slug already exists")
+		return
+	}
+
+	// Reserve the old slug before giving it up so nobody can take it in between.
+	now := time.Now().UTC()
+	if _, err := h.SlugRedirects.UpdateOne(c,
+		bson.M{"slug": site.Slug},
+		bson.M{"$set": bson.M{"siteId": siteID}, "$setOnInsert": bson.M{"createdAt": now}},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to reserve old slug")
+		return
+	}
+
+	userID, _ := getUserID(c)
+	result, err := h.Sites.UpdateOne(c,
+		bson.M{"_id": siteID, "slug": site.Slug},
+		bson.M{
+			"$set":  bson.M{"slug": slug, "updatedAt": now, "settingsUpdatedAt": now},
+			"$push": bson.M{"slugHistory": models.SlugChange{Slug: site.Slug, ChangedBy: userID, ChangedAt: now}},
+		},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(c, http.StatusConflict, "slug already exists")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "failed to change slug")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusConflict, "slug was changed concurrently, retry")
+		return
+	}
+	// Taking back one of the site's own old slugs ends its redirect.
+	if _, err := h.SlugRedirects.DeleteOne(c, bson.M{"slug": slug, "siteId": siteID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update redirects")
+		return
+	}
+	h.Events.Publish(c, siteID, events.SiteUpdated, map[string]interface{}{"slug": slug, "previousSlug": site.Slug})
+	c.JSON(http.StatusOK, gin.H{"slug": slug, "previousSlug": site.Slug})
+}
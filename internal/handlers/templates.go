@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errTemplateNotFound = errors.New("template not found")
+
+// TemplateHandler manages site templates. Superadmins maintain them; anyone
+// can list them when picking a starting point.
+type TemplateHandler struct {
+	Templates *mongo.Collection
+}
+
+type templateRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Category    string                 `json:"category"`
+	Description string                 `json:"description"`
+	PreviewURL  string                 `json:"previewUrl"`
+	Content     map[string]interface{} `json:"content" binding:"required"`
+}
+
+// ListPublic returns the template catalogue without the template content.
+func (h *TemplateHandler) ListPublic(c *gin.Context) {
+	h.list(c, options.Find().SetProjection(bson.M{"content": 0}))
+}
+
+func (h *TemplateHandler) List(c *gin.Context) {
+	h.list(c, options.Find())
+}
+
+func (h *TemplateHandler) list(c *gin.Context, opts *options.FindOptions) {
+	filter := bson.M{}
+	if category := c.Query("category"); category != "" {
+		filter["category"] = category
+	}
+	cursor, err := h.Templates.Find(c, filter, opts.SetSort(bson.D{{Key: "category", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch templates")
+		return
+	}
+	defer cursor.Close(c)
+	templates := []models.Template{}
+	if err := cursor.All(c, &templates); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode templates")
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *TemplateHandler) Create(c *gin.Context) {
+	req, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	template := models.Template{
+		Name:        req.Name,
+		Category:    req.Category,
+		Description: req.Description,
+		PreviewURL:  req.PreviewURL,
+		Content:     req.Content,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	res, err := h.Templates.InsertOne(c, template)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create template")
+		return
+	}
+	template.ID = res.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *TemplateHandler) Update(c *gin.Context) {
+	templateID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid template id")
+		return
+	}
+	req, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+	var template models.Template
+	err = h.Templates.FindOneAndUpdate(c, bson.M{"_id": templateID},
+		bson.M{"$set": bson.M{
+			"name":        req.Name,
+			"category":    req.Category,
+			"description": req.Description,
+			"previewUrl":  req.PreviewURL,
+			"content":     req.Content,
+			"updatedAt":   time.Now().UTC(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, "template not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update template")
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	templateID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid template id")
+		return
+	}
+	result, err := h.Templates.DeleteOne(c, bson.M{"_id": templateID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete template")
+		return
+	}
+	if result.DeletedCount == 0 {
+		respondError(c, http.StatusNotFound, "template not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func bindTemplate(c *gin.Context) (templateRequest, bool) {
+	var req templateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	if req.Name == "" {
+		respondError(c, http.StatusBadRequest, "name is required")
+		return req, false
+	}
+	req.Content = pages.Normalize(req.Content)
+	if errs := pages.ValidateContent(req.Content); len(errs) > 0 {
+		respondInvalidContent(c, errs)
+		return req, false
+	}
+	return req, true
+}
+
+// starterContent returns the content a new site starts with: the template's
+// content when templateID is set, otherwise the built-in starter.
+func starterContent(ctx context.Context, templates *mongo.Collection, templateID string) (map[string]interface{}, error) {
+	if templateID == "" {
+		return defaultStarterContent(), nil
+	}
+	id, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		return nil, errTemplateNotFound
+	}
+	var template models.Template
+	err = templates.FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"content": 1})).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, errTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if template.Content == nil {
+		return map[string]interface{}{}, nil
+	}
+	return pages.Normalize(template.Content), nil
+}
+
+func defaultStarterContent() map[string]interface{} {
+	return map[string]interface{}{
+		"pages": []map[string]interface{}{{
+			"id":    pages.HomeID,
+			"path":  pages.HomePath,
+			"title": "Ana Sayfa",
+			"order": 0,
+			"sections": []map[string]interface{}{
+				{"type": "hero", "data": map[string]interface{}{"title": "Hoş geldin", "subtitle": "Siten hazır"}},
+				{"type": "cta", "data": map[string]interface{}{"title": "İletişim", "buttonText": "Teklif Al", "buttonHref": "#contact"}},
+			},
+		}},
+		"navigation": []map[string]interface{}{
+			{"label": "Ana Sayfa", "pageId": pages.HomeID},
+		},
+	}
+}
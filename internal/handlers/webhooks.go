@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/webhooks"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxWebhooksPerSite = 10
+	deliveryPageSize   = 50
+)
+
+type WebhookHandler struct {
+	Sites           *mongo.Collection
+	SitePermissions *mongo.Collection
+	Webhooks        *mongo.Collection
+	Deliveries      *mongo.Collection
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	Active *bool    `json:"active"`
+}
+
+// webhookSecretResponse is the only response that carries the secret.
+type webhookSecretResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+type deliveryResponse struct {
+	models.WebhookDelivery
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (h *WebhookHandler) sites() *SiteHandler {
+	return &SiteHandler{Sites: h.Sites, SitePermissions: h.SitePermissions}
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	cursor, err := h.Webhooks.Find(c, bson.M{"siteId": siteID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch webhooks")
+		return
+	}
+	defer cursor.Close(c)
+	list := []models.Webhook{}
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	hookURL, hookEvents, ok := validateWebhook(c, req)
+	if !ok {
+		return
+	}
+	count, err := h.Webhooks.CountDocuments(c, bson.M{"siteId": siteID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	if count >= maxWebhooksPerSite {
+		respondError(c, http.StatusConflict, "too many webhooks")
+		return
+	}
+	secret, err := utils.NewSecretToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	userID, _ := getUserID(c)
+
+	now := time.Now().UTC()
+	hook := models.Webhook{
+		SiteID:    siteID,
+		URL:       hookURL,
+		Events:    hookEvents,
+		Secret:    secret,
+		Active:    req.Active == nil || *req.Active,
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := h.Webhooks.InsertOne(c, hook)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	hook.ID = result.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, webhookSecretResponse{Webhook: hook, Secret: secret})
+}
+
+// Update replaces the URL and events of a webhook. Active is kept when
+// omitted.
+func (h *WebhookHandler) Update(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request")
+		return
+	}
+	hookURL, hookEvents, ok := validateWebhook(c, req)
+	if !ok {
+		return
+	}
+	hook.URL = hookURL
+	hook.Events = hookEvents
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	hook.UpdatedAt = time.Now().UTC()
+	update := bson.M{"$set": bson.M{"url": hook.URL, "events": hook.Events, "active": hook.Active, "updatedAt": hook.UpdatedAt}}
+	if _, err := h.Webhooks.UpdateOne(c, bson.M{"_id": hook.ID}, update); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update webhook")
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// RotateSecret replaces the signing secret. Deliveries sent from now on,
+// including retries, are signed with the new one.
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	secret, err := utils.NewSecretToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to rotate secret")
+		return
+	}
+	hook.UpdatedAt = time.Now().UTC()
+	if _, err := h.Webhooks.UpdateOne(c, bson.M{"_id": hook.ID}, bson.M{"$set": bson.M{"secret": secret, "updatedAt": hook.UpdatedAt}}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to rotate secret")
+		return
+	}
+	c.JSON(http.StatusOK, webhookSecretResponse{Webhook: hook, Secret: secret})
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Webhooks.DeleteOne(c, bson.M{"_id": hook.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+	if _, err := h.Deliveries.DeleteMany(c, bson.M{"webhookId": hook.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete webhook deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListDeliveries returns the most recent deliveries of a webhook, newest
+// first, optionally filtered by status.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	filter := bson.M{"webhookId": hook.ID}
+	switch status := c.Query("status"); status {
+	case "":
+	case webhooks.StatusPending, webhooks.StatusSucceeded, webhooks.StatusFailed:
+		filter["status"] = status
+	default:
+		respondError(c, http.StatusBadRequest, "invalid status")
+		return
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(deliveryPageSize)
+	cursor, err := h.Deliveries.Find(c, filter, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to fetch deliveries")
+		return
+	}
+	defer cursor.Close(c)
+	var list []models.WebhookDelivery
+	if err := cursor.All(c, &list); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to decode deliveries")
+		return
+	}
+	response := make([]deliveryResponse, 0, len(list))
+	for _, d := range list {
+		response = append(response, newDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Redeliver queues a delivery again, right away and with a fresh set of
+// retries. The payload is the one originally queued.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	var delivery models.WebhookDelivery
+	if err := h.Deliveries.FindOne(c, bson.M{"_id": deliveryID, "webhookId": hook.ID}).Decode(&delivery); err != nil {
+		respondError(c, http.StatusNotFound, "delivery not found")
+		return
+	}
+	webhooks.Requeue(&delivery, time.Now().UTC())
+	update := bson.M{
+		"$set":   bson.M{"status": delivery.Status, "tries": delivery.Tries, "nextAttemptAt": delivery.NextAttemptAt, "updatedAt": delivery.UpdatedAt},
+		"$unset": bson.M{"deliveredAt": ""},
+	}
+	if _, err := h.Deliveries.UpdateOne(c, bson.M{"_id": delivery.ID}, update); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to redeliver")
+		return
+	}
+	c.JSON(http.StatusAccepted, newDeliveryResponse(delivery))
+}
+
+func (h *WebhookHandler) findWebhook(c *gin.Context) (models.Webhook, bool) {
+	siteID, ok := h.sites().requireOwner(c)
+	if !ok {
+		return models.Webhook{}, false
+	}
+	webhookID, err := primitive.ObjectIDFromHex(c.Param("webhookId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid webhook id")
+		return models.Webhook{}, false
+	}
+	var hook models.Webhook
+	if err := h.Webhooks.FindOne(c, bson.M{"_id": webhookID, "siteId": siteID}).Decode(&hook); err != nil {
+		respondError(c, http.StatusNotFound, "webhook not found")
+		return models.Webhook{}, false
+	}
+	return hook, true
+}
+
+// validateWebhook checks the URL and events of req and returns them
+// normalized, with duplicate events dropped.
+func validateWebhook(c *gin.Context, req webhookRequest) (string, []string, bool) {
+	hookURL := strings.TrimSpace(req.URL)
+	if !webhooks.ValidURL(hookURL) {
+		respondError(c, http.StatusBadRequest, "invalid webhook url")
+		return "", nil, false
+	}
+	hookEvents := []string{}
+	seen := map[string]bool{}
+	for _, event := range req.Events {
+		if !webhooks.ValidEvent(event) {
+			respondError(c, http.StatusBadRequest, "unsupported event "+event)
+			return "", nil, false
+		}
+		if !seen[event] {
+			seen[event] = true
+			hookEvents = append(hookEvents, event)
+		}
+	}
+	if len(hookEvents) == 0 {
+		respondError(c, http.StatusBadRequest, "at least one event is required")
+		return "", nil, false
+	}
+	return hookURL, hookEvents, true
+}
+
+func newDeliveryResponse(d models.WebhookDelivery) deliveryResponse {
+	return deliveryResponse{WebhookDelivery: d, Payload: json.RawMessage(d.Payload)}
+}
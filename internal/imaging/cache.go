@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache keeps rendered resizes on local disk. Every replica has its own
+// cache; entries are derived data and the directory may be wiped at any time.
+type DiskCache struct {
+	Dir string
+}
+
+func (c *DiskCache) Get(name string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(c.Dir, filepath.Base(name)))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put writes through a temporary file so concurrent readers never see a
+// partial entry.
+func (c *DiskCache) Put(name string, data []byte) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, ".resize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, filepath.Base(name)))
+}
+
+// RemovePrefix drops every entry whose name starts with prefix.
+func (c *DiskCache) RemovePrefix(prefix string) error {
+	matches, err := filepath.Glob(filepath.Join(c.Dir, filepath.Base(prefix)+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range matches {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
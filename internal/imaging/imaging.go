@@ -0,0 +1,268 @@
+// Package imaging prepares uploaded images for the web: it strips metadata,
+// applies EXIF orientation and renders resized variants. Everything is pure
+// Go. The standard library and x/image cannot encode WebP, so WebP uploads
+// are only cleaned of metadata and variants are produced for JPEG and PNG.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	// MaxPixels bounds decoded images to keep memory in check.
+	MaxPixels = 50_000_000
+	// MaxWidth is the widest resize that may be requested.
+	MaxWidth = 4096
+
+	originalQuality = 90
+	variantQuality  = 82
+)
+
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// VariantSpec describes a precomputed variant. Box variants fit inside a
+// Width×Width square; the others are scaled to Width.
+type VariantSpec struct {
+	Name  string
+	Width int
+	Box   bool
+}
+
+var DefaultVariants = []VariantSpec{
+	{Name: "thumb", Width: 320, Box: true},
+	{Name: "w640", Width: 640},
+	{Name: "w1280", Width: 1280},
+	{Name: "w1920", Width: 1920},
+}
+
+// ResizeWidths are the widths rendered on demand: the variant widths and a
+// few common breakpoints. Requests are rounded up to the next one, so each
+// image is resized to at most this many widths.
+var ResizeWidths = []int{160, 320, 480, 640, 960, 1280, 1600, 1920, 2560, 3840}
+
+// ResizeWidth returns the on-demand width serving a request for width. It
+// reports false when width is wider than all of them.
+func ResizeWidth(width int) (int, bool) {
+	for _, w := range ResizeWidths {
+		if w >= width {
+			return w, true
+		}
+	}
+	return 0, false
+}
+
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+type Result struct {
+	// Data is the original with metadata removed and orientation applied.
+	Data     []byte
+	Width    int
+	Height   int
+	Variants []Variant
+}
+
+// Resizable reports whether variants can be rendered for contentType.
+func Resizable(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// Process cleans an upload of the given sniffed content type and renders
+// DefaultVariants narrower than the image. Types it does not know are
+// returned unchanged.
+func Process(data []byte, contentType string) (*Result, error) {
+	switch contentType {
+	case "image/jpeg":
+		return processJPEG(data)
+	case "image/png":
+		clean, err := stripPNG(data)
+		if err != nil {
+			return nil, err
+		}
+		img, err := decode(clean, png.DecodeConfig, png.Decode)
+		if err != nil {
+			return nil, err
+		}
+		return withVariants(clean, img, contentType)
+	case "image/webp":
+		clean, err := stripWebP(data)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := webp.DecodeConfig(bytes.NewReader(clean))
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Data: clean, Width: cfg.Width, Height: cfg.Height}, nil
+	case "image/gif":
+		cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Data: data, Width: cfg.Width, Height: cfg.Height}, nil
+	}
+	return &Result{Data: data}, nil
+}
+
+// processJPEG strips metadata losslessly. Only rotated photos are decoded
+// and re-encoded, since orientation has to be baked into the pixels once the
+// EXIF tag is gone.
+func processJPEG(data []byte) (*Result, error) {
+	clean, orientation, err := stripJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decode(clean, jpeg.DecodeConfig, jpeg.Decode)
+	if err != nil {
+		return nil, err
+	}
+	if orientation != 1 {
+		img = orient(img, orientation)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: originalQuality}); err != nil {
+			return nil, err
+		}
+		clean = buf.Bytes()
+	}
+	return withVariants(clean, img, "image/jpeg")
+}
+
+func withVariants(clean []byte, img image.Image, contentType string) (*Result, error) {
+	bounds := img.Bounds()
+	result := &Result{Data: clean, Width: bounds.Dx(), Height: bounds.Dy()}
+	variants := make([]Variant, len(DefaultVariants))
+	// Largest first, each rendered from the previous one, which is much
+	// cheaper than scaling every variant from a full-size photo.
+	src := img
+	for i := len(DefaultVariants) - 1; i >= 0; i-- {
+		spec := DefaultVariants[i]
+		width, height := fit(bounds.Dx(), bounds.Dy(), spec)
+		if width >= bounds.Dx() {
+			continue
+		}
+		scaled := scale(src, width, height)
+		data, err := encode(scaled, contentType)
+		if err != nil {
+			return nil, err
+		}
+		variants[i] = Variant{Name: spec.Name, Width: width, Height: height, Data: data}
+		src = scaled
+	}
+	for _, v := range variants {
+		if v.Data != nil {
+			result.Variants = append(result.Variants, v)
+		}
+	}
+	return result, nil
+}
+
+// Resize renders data at width, keeping the aspect ratio. The caller makes
+// sure width is below the image width.
+func Resize(data []byte, contentType string, width int) ([]byte, error) {
+	var img image.Image
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		img, err = decode(data, jpeg.DecodeConfig, jpeg.Decode)
+	case "image/png":
+		img, err = decode(data, png.DecodeConfig, png.Decode)
+	default:
+		return nil, errors.New("unsupported image type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	width, height := fit(bounds.Dx(), bounds.Dy(), VariantSpec{Width: width})
+	return encode(scale(img, width, height), contentType)
+}
+
+func decode(data []byte, decodeConfig func(r io.Reader) (image.Config, error), decodeImage func(r io.Reader) (image.Image, error)) (image.Image, error) {
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	return decodeImage(bytes.NewReader(data))
+}
+
+func fit(width, height int, spec VariantSpec) (int, int) {
+	if spec.Box && height > width {
+		w := width * spec.Width / height
+		return max(w, 1), spec.Width
+	}
+	h := height * spec.Width / width
+	return spec.Width, max(h, 1)
+}
+
+func scale(src image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: variantQuality})
+	}
+	return buf.Bytes(), err
+}
+
+// orient applies an EXIF orientation (2-8) to img.
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
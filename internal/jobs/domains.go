@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	pendingRecheck  = 5 * time.Minute
+	verifiedRecheck = 24 * time.Hour
+)
+
+// DomainVerifier re-checks custom domains: pending ones every few minutes
+// until they verify or time out, the rest once a day so removed records are
+// noticed.
+type DomainVerifier struct {
+	Verifier *domains.Verifier
+}
+
+func (j *DomainVerifier) Tick(ctx context.Context) error {
+	now := time.Now().UTC()
+	filter := bson.M{"$or": []bson.M{
+		{"status": domains.StatusPending, "$or": []bson.M{
+			{"lastCheckedAt": nil},
+			{"lastCheckedAt": bson.M{"$lte": now.Add(-pendingRecheck)}},
+		}},
+		{"status": bson.M{"$ne": domains.StatusPending}, "lastCheckedAt": bson.M{"$lte": now.Add(-verifiedRecheck)}},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "lastCheckedAt", Value: 1}}).SetLimit(100)
+	cursor, err := j.Verifier.Domains.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("find domains to verify: %w", err)
+	}
+	var due []models.SiteDomain
+	if err := cursor.All(ctx, &due); err != nil {
+		return fmt.Errorf("decode domains to verify: %w", err)
+	}
+	for i := range due {
+		if err := j.Verifier.Verify(ctx, &due[i]); err != nil {
+			log.Printf("domain-verifier: verify %s: %v", due[i].Hostname, err)
+		}
+	}
+	return nil
+}
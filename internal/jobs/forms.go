@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
+)
+
+// FormNotifier emails site owners about new form submissions.
+type FormNotifier struct {
+	Notifier *forms.Notifier
+}
+
+func (j *FormNotifier) Tick(ctx context.Context) error {
+	return j.Notifier.SendDue(ctx)
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lease is a named, expiring lock stored in Mongo. Only the current holder of
+// a lease runs the job it guards, so jobs are safe to start on every replica.
+type Lease struct {
+	Leases *mongo.Collection
+	Name   string
+	Holder string
+	TTL    time.Duration
+}
+
+// NewHolderID returns an identifier unique to this process.
+func NewHolderID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+}
+
+// Acquire takes or renews the lease. It reports false when another holder
+// owns an unexpired lease.
+func (l *Lease) Acquire(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": l.Name,
+		"$or": []bson.M{
+			{"holder": l.Holder},
+			{"expiresAt": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": l.Holder, "expiresAt": now.Add(l.TTL), "renewedAt": now}}
+	_, err := l.Leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// The upsert collides with the existing document when someone else holds it.
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Release gives the lease up early so another replica can pick it up.
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := l.Leases.DeleteOne(ctx, bson.M{"_id": l.Name, "holder": l.Holder})
+	return err
+}
@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SiteScopedCollections lists the collections whose documents belong to a
+// single site through a siteId field. Purging a site removes them all.
+var SiteScopedCollections = []string{
+	"site_permissions",
+	"preview_tokens",
+	"site_reviews",
+	"comment_threads",
+	"site_locks",
+	"site_events",
+	"site_presence",
+	"slug_redirects",
+	"assets",
+	"site_domains",
+	"webhooks",
+	"webhook_deliveries",
+	"forms",
+	"form_submissions",
+}
+
+// TrashPurger hard-deletes sites that have been in the trash longer than
+// Retention, together with everything that belongs to them.
+type TrashPurger struct {
+	DB        *mongo.Database
+	Storage   storage.Storage
+	Retention time.Duration
+}
+
+func (p *TrashPurger) Tick(ctx context.Context) error {
+	sites := p.DB.Collection("sites")
+	cutoff := time.Now().UTC().Add(-p.Retention)
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(50)
+	cursor, err := sites.Find(ctx, bson.M{"deletedAt": bson.M{"$lte": cutoff}}, opts)
+	if err != nil {
+		return fmt.Errorf("find expired sites: %w", err)
+	}
+	var expired []models.Site
+	if err := cursor.All(ctx, &expired); err != nil {
+		return fmt.Errorf("decode expired sites: %w", err)
+	}
+
+	for _, site := range expired {
+		// Related data goes first so an interrupted purge is retried on the
+		// next tick instead of leaving orphans behind.
+		if err := p.purgeAssetFiles(ctx, site.ID); err != nil {
+			return err
+		}
+		for _, name := range SiteScopedCollections {
+			if _, err := p.DB.Collection(name).DeleteMany(ctx, bson.M{"siteId": site.ID}); err != nil {
+				return fmt.Errorf("purge %s of site %s: %w", name, site.ID.Hex(), err)
+			}
+		}
+		if _, err := sites.DeleteOne(ctx, bson.M{"_id": site.ID, "deletedAt": bson.M{"$lte": cutoff}}); err != nil {
+			return fmt.Errorf("purge site %s: %w", site.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// purgeAssetFiles removes the stored files and variants of a site's assets.
+// The records themselves go with the other site-scoped collections.
+func (p *TrashPurger) purgeAssetFiles(ctx context.Context, siteID primitive.ObjectID) error {
+	cursor, err := p.DB.Collection("assets").Find(ctx, bson.M{"siteId": siteID}, options.Find().SetProjection(bson.M{"key": 1, "variants.key": 1}))
+	if err != nil {
+		return fmt.Errorf("find assets of site %s: %w", siteID.Hex(), err)
+	}
+	var assets []models.Asset
+	if err := cursor.All(ctx, &assets); err != nil {
+		return fmt.Errorf("decode assets of site %s: %w", siteID.Hex(), err)
+	}
+	for _, asset := range assets {
+		keys := []string{asset.Key}
+		for _, v := range asset.Variants {
+			keys = append(keys, v.Key)
+		}
+		for _, key := range keys {
+			if err := p.Storage.Delete(ctx, key); err != nil {
+				return fmt.Errorf("delete asset %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
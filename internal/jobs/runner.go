@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunLeased calls fn every interval while this process holds the lease. It
+// returns when ctx is cancelled.
+func RunLeased(ctx context.Context, lease *Lease, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = lease.Release(releaseCtx)
+	}()
+
+	for {
+		held, err := lease.Acquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("%s: acquire lease: %v", lease.Name, err)
+		}
+		if held {
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("%s: %v", lease.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/publishing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PublishScheduler applies due publishAt and unpublishAt timestamps.
+type PublishScheduler struct {
+	Sites  *mongo.Collection
+	Events *events.Bus
+}
+
+// Tick publishes and unpublishes every site whose scheduled time has passed.
+// Each update re-checks the schedule in its filter, so a tick that overlaps
+// with another replica or a manual action is harmless.
+func (s *PublishScheduler) Tick(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	due, err := s.dueSites(ctx, "publishAt", now)
+	if err != nil {
+		return fmt.Errorf("find scheduled publishes: %w", err)
+	}
+	for _, site := range due {
+		result, err := publishing.Publish(ctx, s.Sites, bson.M{"_id": site.ID, "publishAt": bson.M{"$lte": now}}, now)
+		if err != nil {
+			return fmt.Errorf("publish site %s: %w", site.ID.Hex(), err)
+		}
+		if result.ModifiedCount > 0 {
+			s.Events.Publish(ctx, site.ID, events.SitePublished, map[string]interface{}{"scheduled": true})
+			continue
+		}
+		if result.MatchedCount == 0 {
+			if err := s.dropPublish(ctx, site.ID, now); err != nil {
+				return fmt.Errorf("drop scheduled publish of site %s: %w", site.ID.Hex(), err)
+			}
+		}
+	}
+
+	due, err = s.dueSites(ctx, "unpublishAt", now)
+	if err != nil {
+		return fmt.Errorf("find scheduled unpublishes: %w", err)
+	}
+	for _, site := range due {
+		result, err := publishing.Unpublish(ctx, s.Sites, bson.M{"_id": site.ID, "unpublishAt": bson.M{"$lte": now}}, now)
+		if err != nil {
+			return fmt.Errorf("unpublish site %s: %w", site.ID.Hex(), err)
+		}
+		if result.ModifiedCount > 0 {
+			s.Events.Publish(ctx, site.ID, events.SiteUnpublished, map[string]interface{}{"scheduled": true})
+		}
+	}
+	return nil
+}
+
+// dropPublish clears a due publishAt that Publish could not apply, recording
+// why, so the site is not retried on every tick and does not hold up the
+// sites scheduled after it.
+func (s *PublishScheduler) dropPublish(ctx context.Context, siteID primitive.ObjectID, now time.Time) error {
+	var site models.Site
+	err := s.Sites.FindOne(ctx, bson.M{"_id": siteID}).Decode(&site)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if site.PublishAt == nil || site.PublishAt.After(now) {
+		// Rescheduled or cancelled in the meantime.
+		return nil
+	}
+	reason := "content is not approved"
+	switch {
+	case site.DeletedAt != nil:
+		reason = "site is deleted"
+	case site.ArchivedAt != nil:
+		reason = "site is archived"
+	}
+	result, err := s.Sites.UpdateOne(ctx,
+		bson.M{"_id": siteID, "publishAt": site.PublishAt},
+		bson.M{"$unset": bson.M{"publishAt": ""}, "$set": bson.M{"publishMissed": models.ScheduleMiss{At: now, Reason: reason}, "updatedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		s.Events.Publish(ctx, siteID, events.SiteUpdated, map[string]interface{}{"publishMissed": reason})
+	}
+	return nil
+}
+
+func (s *PublishScheduler) dueSites(ctx context.Context, field string, now time.Time) ([]models.Site, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.D{{Key: field, Value: 1}}).SetLimit(100)
+	cursor, err := s.Sites.Find(ctx, bson.M{field: bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var sites []models.Site
+	if err := cursor.All(ctx, &sites); err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/webhooks"
+)
+
+// WebhookDispatcher queues deliveries for site events that were not queued
+// when they were published and sends the deliveries that are due, including
+// retries.
+type WebhookDispatcher struct {
+	Dispatcher *webhooks.Dispatcher
+}
+
+func (j *WebhookDispatcher) Tick(ctx context.Context) error {
+	if err := j.Dispatcher.Enqueue(ctx); err != nil {
+		return err
+	}
+	return j.Dispatcher.DeliverDue(ctx)
+}
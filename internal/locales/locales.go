@@ -0,0 +1,84 @@
+// Package locales handles the language tags sites are published in: a
+// language with an optional region, such as "tr", "en" or "pt-BR".
+package locales
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale of sites that declare none.
+const Default = "tr"
+
+var tagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// rtl lists the languages written right to left.
+var rtl = map[string]bool{"ar": true, "fa": true, "he": true, "ur": true, "ps": true, "yi": true, "dv": true, "ckb": true}
+
+// Normalize canonicalizes the case of tag and reports whether it is valid.
+func Normalize(tag string) (string, bool) {
+	lang, region, hasRegion := strings.Cut(strings.TrimSpace(tag), "-")
+	tag = strings.ToLower(lang)
+	if hasRegion {
+		tag += "-" + strings.ToUpper(region)
+	}
+	return tag, tagPattern.MatchString(tag)
+}
+
+// Valid reports whether tag is a canonical locale.
+func Valid(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// IsRTL reports whether tag is written right to left.
+func IsRTL(tag string) bool {
+	lang, _, _ := strings.Cut(tag, "-")
+	return rtl[lang]
+}
+
+// Negotiate picks the best of supported for an Accept-Language header. An
+// exact match wins over a language-only match; "" means nothing matched.
+func Negotiate(header string, supported []string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || q <= 0 {
+			continue
+		}
+		choices = append(choices, choice{tag, q})
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, ch := range choices {
+		want, ok := Normalize(ch.tag)
+		if !ok {
+			continue
+		}
+		for _, tag := range supported {
+			if tag == want {
+				return tag
+			}
+		}
+		lang, _, _ := strings.Cut(want, "-")
+		for _, tag := range supported {
+			if supportedLang, _, _ := strings.Cut(tag, "-"); supportedLang == lang {
+				return tag
+			}
+		}
+	}
+	return ""
+}
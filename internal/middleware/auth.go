@@ -3,9 +3,13 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 const (
@@ -27,18 +31,50 @@ func AuthRequired(secret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ParseToken(parts[1], secret)
-		if err != nil || claims.Subject == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		authenticate(c, parts[1], secret)
+	}
+}
+
+// StreamAuthRequired authenticates like AuthRequired but also accepts a
+// stream ticket in the ticket query parameter, because browsers cannot set
+// headers on EventSource requests. A ticket is used up by the first request
+// and only opens the stream of the site it was issued for.
+func StreamAuthRequired(secret string, tickets *mongo.Collection) gin.HandlerFunc {
+	header := AuthRequired(secret)
+	return func(c *gin.Context) {
+		raw := c.Query("ticket")
+		if raw == "" {
+			header(c)
 			return
 		}
-
-		c.Set(ContextUserID, claims.Subject)
-		c.Set(ContextGlobalRole, claims.GlobalRole)
+		var ticket models.StreamTicket
+		err := tickets.FindOneAndDelete(c, bson.M{"_id": utils.HashToken(raw), "expiresAt": bson.M{"$gt": time.Now().UTC()}}).Decode(&ticket)
+		if err == mongo.ErrNoDocuments || (err == nil && ticket.SiteID.Hex() != c.Param("id")) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid ticket"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check ticket"})
+			return
+		}
+		c.Set(ContextUserID, ticket.UserID.Hex())
+		c.Set(ContextGlobalRole, ticket.GlobalRole)
 		c.Next()
 	}
 }
 
+func authenticate(c *gin.Context, token, secret string) {
+	claims, err := utils.ParseToken(token, secret)
+	if err != nil || claims.Subject == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	c.Set(ContextUserID, claims.Subject)
+	c.Set(ContextGlobalRole, claims.GlobalRole)
+	c.Next()
+}
+
 func SuperAdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, ok := c.Get(ContextGlobalRole)
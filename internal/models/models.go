@@ -15,26 +15,308 @@ type User struct {
 	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
+// Site keeps the editable draft in Content and the snapshot taken at publish
+// time in PublishedContent. Only the snapshot is served publicly. Version is
+// bumped on every content change and doubles as the ETag for edits. Archived
+// sites are read-only; deleted sites sit in the trash until they are purged.
 type Site struct {
+	ID                    primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Name                  string                 `bson:"name" json:"name"`
+	Slug                  string                 `bson:"slug" json:"slug"`
+	Status                string                 `bson:"status" json:"status"`
+	Content               map[string]interface{} `bson:"content" json:"content"`
+	PublishedContent      map[string]interface{} `bson:"publishedContent,omitempty" json:"publishedContent,omitempty"`
+	HasUnpublishedChanges bool                   `bson:"hasUnpublishedChanges" json:"hasUnpublishedChanges"`
+	Version               int64                  `bson:"version" json:"version"`
+	CreatedAt             time.Time              `bson:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time              `bson:"updatedAt" json:"updatedAt"`
+	SettingsUpdatedAt     *time.Time             `bson:"settingsUpdatedAt,omitempty" json:"settingsUpdatedAt,omitempty"`
+	PublishedAt           *time.Time             `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
+	PublishAt             *time.Time             `bson:"publishAt,omitempty" json:"publishAt,omitempty"`
+	UnpublishAt           *time.Time             `bson:"unpublishAt,omitempty" json:"unpublishAt,omitempty"`
+	PublishMissed         *ScheduleMiss          `bson:"publishMissed,omitempty" json:"publishMissed,omitempty"`
+	Workflow              *SiteWorkflow          `bson:"workflow,omitempty" json:"workflow,omitempty"`
+	Locales               *SiteLocales           `bson:"locales,omitempty" json:"locales,omitempty"`
+	Settings              *SiteSettings          `bson:"settings,omitempty" json:"settings,omitempty"`
+	ArchivedAt            *time.Time             `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"`
+	DeletedAt             *time.Time             `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+	SlugHistory           []SlugChange           `bson:"slugHistory,omitempty" json:"slugHistory,omitempty"`
+}
+
+// ScheduleMiss records why a scheduled publish was dropped instead of applied.
+type ScheduleMiss struct {
+	At     time.Time `bson:"at" json:"at"`
+	Reason string    `bson:"reason" json:"reason"`
+}
+
+// StreamTicket lets an EventSource open the event stream of one site without
+// an access token in its URL. Tickets are single-use and short-lived; the id
+// is the SHA-256 hash of the ticket.
+type StreamTicket struct {
+	ID         string             `bson:"_id"`
+	SiteID     primitive.ObjectID `bson:"siteId"`
+	UserID     primitive.ObjectID `bson:"userId"`
+	GlobalRole string             `bson:"globalRole"`
+	ExpiresAt  time.Time          `bson:"expiresAt"`
+}
+
+// SlugChange records a slug the site used to have.
+type SlugChange struct {
+	Slug      string             `bson:"slug" json:"slug"`
+	ChangedBy primitive.ObjectID `bson:"changedBy" json:"changedBy"`
+	ChangedAt time.Time          `bson:"changedAt" json:"changedAt"`
+}
+
+// SiteWorkflow is the optional review step in front of publishing. State is
+// one of in_review, changes_requested or approved. Approval covers exactly one
+// content version; any later edit needs a new review.
+type SiteWorkflow struct {
+	Enabled          bool                 `bson:"enabled" json:"enabled"`
+	Approvers        []primitive.ObjectID `bson:"approvers,omitempty" json:"approvers,omitempty"`
+	State            string               `bson:"state,omitempty" json:"state,omitempty"`
+	SubmittedVersion int64                `bson:"submittedVersion,omitempty" json:"submittedVersion,omitempty"`
+	ApprovedVersion  int64                `bson:"approvedVersion,omitempty" json:"approvedVersion,omitempty"`
+}
+
+// SiteLocales lists the languages a site is published in. Content written in
+// Default is the fallback for missing translations.
+type SiteLocales struct {
+	Default    string   `bson:"default" json:"default"`
+	Additional []string `bson:"additional" json:"additional"`
+}
+
+// SiteSettings holds site-wide metadata. Title, Description, OGImage and
+// Favicon are public SEO fields and the defaults for pages that set none.
+type SiteSettings struct {
+	Title              string `bson:"title,omitempty" json:"title,omitempty"`
+	Description        string `bson:"description,omitempty" json:"description,omitempty"`
+	OGImage            string `bson:"ogImage,omitempty" json:"ogImage,omitempty"`
+	Favicon            string `bson:"favicon,omitempty" json:"favicon,omitempty"`
+	GoogleAnalyticsID  string `bson:"googleAnalyticsId,omitempty" json:"googleAnalyticsId,omitempty"`
+	GoogleTagManagerID string `bson:"googleTagManagerId,omitempty" json:"googleTagManagerId,omitempty"`
+	ContactEmail       string `bson:"contactEmail,omitempty" json:"contactEmail,omitempty"`
+}
+
+// SiteReview is one entry in a site's review history.
+type SiteReview struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID    primitive.ObjectID `bson:"siteId" json:"siteId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Action    string             `bson:"action" json:"action"`
+	State     string             `bson:"state" json:"state"`
+	Version   int64              `bson:"version" json:"version"`
+	Comment   string             `bson:"comment,omitempty" json:"comment,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type SitePermission struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID    primitive.ObjectID `bson:"siteId" json:"siteId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Role      string             `bson:"role" json:"role"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// PreviewToken grants read access to a site's draft without an account. Only
+// the SHA-256 hash of the token is stored.
+type PreviewToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID    primitive.ObjectID `bson:"siteId" json:"siteId"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	Label     string             `bson:"label,omitempty" json:"label,omitempty"`
+	CreatedBy primitive.ObjectID `bson:"createdBy" json:"createdBy"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// CommentThread is a discussion on a site, optionally anchored to a section
+// or to a JSON Pointer path inside the content.
+type CommentThread struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	SiteID     primitive.ObjectID  `bson:"siteId" json:"siteId"`
+	Anchor     *CommentAnchor      `bson:"anchor,omitempty" json:"anchor,omitempty"`
+	Resolved   bool                `bson:"resolved" json:"resolved"`
+	ResolvedBy *primitive.ObjectID `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time          `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	Comments   []Comment           `bson:"comments" json:"comments"`
+	CreatedBy  primitive.ObjectID  `bson:"createdBy" json:"createdBy"`
+	CreatedAt  time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+type CommentAnchor struct {
+	SectionID string `bson:"sectionId,omitempty" json:"sectionId,omitempty"`
+	Path      string `bson:"path,omitempty" json:"path,omitempty"`
+}
+
+type Comment struct {
+	ID        primitive.ObjectID   `bson:"id" json:"id"`
+	AuthorID  primitive.ObjectID   `bson:"authorId" json:"authorId"`
+	Body      string               `bson:"body" json:"body"`
+	Mentions  []primitive.ObjectID `bson:"mentions,omitempty" json:"mentions,omitempty"`
+	CreatedAt time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// SiteLock is an advisory, heartbeat-renewed edit lock. An empty Section
+// locks the whole site; otherwise it names the id of a content section.
+type SiteLock struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID      primitive.ObjectID `bson:"siteId" json:"siteId"`
+	Section     string             `bson:"section" json:"section,omitempty"`
+	HolderID    primitive.ObjectID `bson:"holderId" json:"holderId"`
+	HolderEmail string             `bson:"holderEmail" json:"holderEmail"`
+	AcquiredAt  time.Time          `bson:"acquiredAt" json:"acquiredAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
+
+// Template is a starting point for new sites, maintained by superadmins.
+type Template struct {
 	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
 	Name        string                 `bson:"name" json:"name"`
-	Slug        string                 `bson:"slug" json:"slug"`
-	Status      string                 `bson:"status" json:"status"`
-	Content     map[string]interface{} `bson:"content" json:"content"`
+	Category    string                 `bson:"category,omitempty" json:"category,omitempty"`
+	Description string                 `bson:"description,omitempty" json:"description,omitempty"`
+	PreviewURL  string                 `bson:"previewUrl,omitempty" json:"previewUrl,omitempty"`
+	Content     map[string]interface{} `bson:"content,omitempty" json:"content,omitempty"`
 	CreatedAt   time.Time              `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time              `bson:"updatedAt" json:"updatedAt"`
-	PublishedAt *time.Time             `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
 }
 
-type SitePermission struct {
+// Asset is an uploaded file in a site's media library. The bytes live in
+// the configured storage backend under Key.
+type Asset struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID      primitive.ObjectID `bson:"siteId" json:"siteId"`
+	Key         string             `bson:"key" json:"-"`
+	Filename    string             `bson:"filename" json:"filename"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int                `bson:"height,omitempty" json:"height,omitempty"`
+	Alt         string             `bson:"alt" json:"alt"`
+	Variants    []AssetVariant     `bson:"variants,omitempty" json:"variants,omitempty"`
+	UploadedBy  primitive.ObjectID `bson:"uploadedBy" json:"uploadedBy"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	URL         string             `bson:"-" json:"url"`
+}
+
+// AssetVariant is a resized rendition of an image asset.
+type AssetVariant struct {
+	Name   string `bson:"name" json:"name"`
+	Key    string `bson:"key" json:"-"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+	Size   int64  `bson:"size" json:"size"`
+	URL    string `bson:"-" json:"url"`
+}
+
+// SiteDomain is a custom hostname attached to a site. It only routes traffic
+// once ownership has been proven with a DNS TXT record.
+type SiteDomain struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID            primitive.ObjectID `bson:"siteId" json:"siteId"`
+	Hostname          string             `bson:"hostname" json:"hostname"`
+	Status            string             `bson:"status" json:"status"`
+	VerificationToken string             `bson:"verificationToken" json:"verificationToken"`
+	LastError         string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	LastCheckedAt     *time.Time         `bson:"lastCheckedAt,omitempty" json:"lastCheckedAt,omitempty"`
+	VerifiedAt        *time.Time         `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt         time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Webhook sends the site events listed in Events to URL. The secret signs
+// deliveries and is only shown when the webhook is created.
+type Webhook struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	SiteID    primitive.ObjectID `bson:"siteId" json:"siteId"`
-	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
-	Role      string             `bson:"role" json:"role"`
+	URL       string             `bson:"url" json:"url"`
+	Events    []string           `bson:"events" json:"events"`
+	Secret    string             `bson:"secret" json:"-"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedBy primitive.ObjectID `bson:"createdBy" json:"createdBy"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
+// WebhookDelivery is one event queued for one webhook. Payload is kept as
+// sent so redeliveries are identical. Tries counts the attempts since the
+// delivery was last queued and drives the backoff; Attempts is the log.
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID     primitive.ObjectID `bson:"webhookId" json:"webhookId"`
+	SiteID        primitive.ObjectID `bson:"siteId" json:"siteId"`
+	EventID       primitive.ObjectID `bson:"eventId" json:"eventId"`
+	Event         string             `bson:"event" json:"event"`
+	Payload       string             `bson:"payload" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	Tries         int                `bson:"tries" json:"tries"`
+	NextAttemptAt *time.Time         `bson:"nextAttemptAt,omitempty" json:"nextAttemptAt,omitempty"`
+	Attempts      []WebhookAttempt   `bson:"attempts" json:"attempts"`
+	DeliveredAt   *time.Time         `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// WebhookAttempt records one request of a delivery.
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	Response   string    `bson:"response,omitempty" json:"response,omitempty"`
+	DurationMs int64     `bson:"durationMs" json:"durationMs"`
+}
+
+// Form is a form visitors of the published site can submit. Submissions are
+// emailed to NotifyEmail, or to the site's contact email when it is empty.
+type Form struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID      primitive.ObjectID `bson:"siteId" json:"siteId"`
+	Name        string             `bson:"name" json:"name"`
+	Fields      []FormField        `bson:"fields" json:"fields"`
+	NotifyEmail string             `bson:"notifyEmail,omitempty" json:"notifyEmail,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// FormField is one input of a form. Options are the choices of a select.
+type FormField struct {
+	Name      string   `bson:"name" json:"name"`
+	Label     string   `bson:"label" json:"label"`
+	Type      string   `bson:"type" json:"type"`
+	Required  bool     `bson:"required" json:"required"`
+	Options   []string `bson:"options,omitempty" json:"options,omitempty"`
+	MaxLength int      `bson:"maxLength,omitempty" json:"maxLength,omitempty"`
+}
+
+// FormSubmission is one submission of a form. FormName is kept so the
+// inbox still reads well after the form is renamed or deleted.
+type FormSubmission struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SiteID       primitive.ObjectID `bson:"siteId" json:"siteId"`
+	FormID       primitive.ObjectID `bson:"formId" json:"formId"`
+	FormName     string             `bson:"formName" json:"formName"`
+	Data         map[string]string  `bson:"data" json:"data"`
+	IPHash       string             `bson:"ipHash" json:"-"`
+	UserAgent    string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	ReadAt       *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	ArchivedAt   *time.Time         `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"`
+	Notification *FormNotification  `bson:"notification,omitempty" json:"notification,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// FormNotification tracks the email sent about a submission.
+type FormNotification struct {
+	To            string     `bson:"to" json:"to"`
+	Status        string     `bson:"status" json:"status"`
+	Attempts      int        `bson:"attempts" json:"attempts"`
+	LastError     string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt *time.Time `bson:"nextAttemptAt,omitempty" json:"-"`
+	SentAt        *time.Time `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+}
+
 // Legacy types still used by existing provisioning flows.
 type ProvisionCodePayload struct {
 	SiteName string `bson:"siteName" json:"siteName"`
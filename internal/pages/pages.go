@@ -0,0 +1,214 @@
+// Package pages models the pages of a site. Pages live inside the content
+// document, next to the navigation menu, so drafts, publishing, versions and
+// locks cover the whole site at once:
+//
+//	{"pages": [{"id": "home", "path": "/", "title": "...", "order": 0,
+//	            "seo": {...}, "sections": [...]}],
+//	 "navigation": [{"label": "...", "pageId": "home"}]}
+package pages
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	HomePath = "/"
+	HomeID   = "home"
+)
+
+type SEO struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	NoIndex     bool   `json:"noIndex,omitempty"`
+}
+
+type Page struct {
+	ID           string                   `json:"id"`
+	Path         string                   `json:"path"`
+	Title        string                   `json:"title"`
+	Order        int                      `json:"order"`
+	SEO          *SEO                     `json:"seo,omitempty"`
+	Sections     []map[string]interface{} `json:"sections"`
+	Translations map[string]Translation   `json:"translations,omitempty"`
+}
+
+// Translation holds a page in one of the site's additional locales. Empty
+// fields and sections fall back to the default locale.
+type Translation struct {
+	Title    string                   `json:"title,omitempty"`
+	SEO      *SEO                     `json:"seo,omitempty"`
+	Sections []map[string]interface{} `json:"sections,omitempty"`
+}
+
+// Localized returns the page as seen in locale, with untranslated fields
+// taken from the default locale.
+func (p Page) Localized(locale string) Page {
+	t, ok := p.Translations[locale]
+	p.Translations = nil
+	if !ok {
+		return p
+	}
+	if t.Title != "" {
+		p.Title = t.Title
+	}
+	if t.Sections != nil {
+		p.Sections = t.Sections
+	}
+	if t.SEO != nil {
+		seo := SEO{}
+		if p.SEO != nil {
+			seo = *p.SEO
+		}
+		if t.SEO.Title != "" {
+			seo.Title = t.SEO.Title
+		}
+		if t.SEO.Description != "" {
+			seo.Description = t.SEO.Description
+		}
+		if t.SEO.Image != "" {
+			seo.Image = t.SEO.Image
+		}
+		seo.NoIndex = seo.NoIndex || t.SEO.NoIndex
+		p.SEO = &seo
+	}
+	return p
+}
+
+// NavItem is a menu entry linking either to a page of the site or to an
+// arbitrary URL. Menus are at most two levels deep. Labels holds the label
+// in additional locales.
+type NavItem struct {
+	Label    string            `json:"label"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	PageID   string            `json:"pageId,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Children []NavItem         `json:"children,omitempty"`
+}
+
+// LabelFor returns the label of the entry in locale.
+func (n NavItem) LabelFor(locale string) string {
+	if label := n.Labels[locale]; label != "" {
+		return label
+	}
+	return n.Label
+}
+
+type Document struct {
+	Pages      []Page    `json:"pages"`
+	Navigation []NavItem `json:"navigation"`
+}
+
+// LocalizeNav returns a copy of items labelled in locale.
+func LocalizeNav(items []NavItem, locale string) []NavItem {
+	if items == nil {
+		return nil
+	}
+	out := make([]NavItem, len(items))
+	for i, item := range items {
+		out[i] = NavItem{Label: item.LabelFor(locale), PageID: item.PageID, URL: item.URL, Children: LocalizeNav(item.Children, locale)}
+	}
+	return out
+}
+
+// Normalize converts single-page content, which keeps its sections at the
+// top level, into a document with one home page. Other content is returned
+// unchanged.
+func Normalize(content map[string]interface{}) map[string]interface{} {
+	if content == nil {
+		return nil
+	}
+	if _, ok := content["pages"]; ok {
+		return content
+	}
+	list, ok := content["sections"]
+	if !ok {
+		return content
+	}
+	out := make(map[string]interface{}, len(content))
+	for key, value := range content {
+		if key != "sections" {
+			out[key] = value
+		}
+	}
+	out["pages"] = []interface{}{map[string]interface{}{
+		"id":       HomeID,
+		"path":     HomePath,
+		"title":    "",
+		"order":    0,
+		"sections": list,
+	}}
+	return out
+}
+
+// Parse reads the pages of content. Content read from Mongo holds BSON
+// types, so it goes through JSON first.
+func Parse(content map[string]interface{}) (Document, error) {
+	var doc Document
+	raw, err := json.Marshal(Normalize(content))
+	if err != nil {
+		return Document{}, fmt.Errorf("parse pages: %w", err)
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return Document{}, fmt.Errorf("parse pages: %w", err)
+	}
+	return doc, nil
+}
+
+// Ordered returns the pages sorted by order, then path.
+func (d Document) Ordered() []Page {
+	out := append([]Page(nil), d.Pages...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Order != out[j].Order {
+			return out[i].Order < out[j].Order
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// Find returns the page served at path. Trailing slashes are ignored.
+func (d Document) Find(path string) (Page, bool) {
+	path = CleanPath(path)
+	for _, p := range d.Pages {
+		if p.Path == path {
+			return p, true
+		}
+	}
+	return Page{}, false
+}
+
+func (d Document) ByID(id string) (Page, bool) {
+	for _, p := range d.Pages {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Page{}, false
+}
+
+// Sections returns the sections of every page in page order, each page's
+// translations following its own sections.
+func (d Document) Sections() []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, p := range d.Ordered() {
+		out = append(out, p.Sections...)
+		locales := make([]string, 0, len(p.Translations))
+		for locale := range p.Translations {
+			locales = append(locales, locale)
+		}
+		sort.Strings(locales)
+		for _, locale := range locales {
+			out = append(out, p.Translations[locale].Sections...)
+		}
+	}
+	return out
+}
+
+// CleanPath returns path with a leading slash and without trailing ones.
+func CleanPath(path string) string {
+	return "/" + strings.Trim(path, "/")
+}
@@ -0,0 +1,205 @@
+package pages
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/locales"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
+
+// MaxPages bounds the size of a site.
+const MaxPages = 50
+
+var seoSchema = map[string]interface{}{
+	"type":                 "object",
+	"additionalProperties": false,
+	"properties": map[string]interface{}{
+		"title":       map[string]interface{}{"type": "string", "maxLength": 120},
+		"description": map[string]interface{}{"type": "string", "maxLength": 320},
+		"image":       map[string]interface{}{"type": "string", "format": "uri-reference"},
+		"noIndex":     map[string]interface{}{"type": "boolean"},
+	},
+}
+
+var pageSchema = map[string]interface{}{
+	"type":                 "object",
+	"required":             []interface{}{"id", "path"},
+	"additionalProperties": false,
+	"properties": map[string]interface{}{
+		"id":           map[string]interface{}{"type": "string", "pattern": `^[a-z0-9]+(-[a-z0-9]+)*$`, "maxLength": 64},
+		"path":         map[string]interface{}{"type": "string", "pattern": `^/([a-z0-9]+(-[a-z0-9]+)*(/[a-z0-9]+(-[a-z0-9]+)*)*)?$`, "maxLength": 200},
+		"title":        map[string]interface{}{"type": "string", "maxLength": 120},
+		"order":        map[string]interface{}{"type": "integer"},
+		"seo":          seoSchema,
+		"sections":     map[string]interface{}{"type": "array"},
+		"translations": map[string]interface{}{"type": "object"},
+	},
+}
+
+var translationSchema = map[string]interface{}{
+	"type":                 "object",
+	"additionalProperties": false,
+	"properties": map[string]interface{}{
+		"title":    map[string]interface{}{"type": "string", "maxLength": 120},
+		"seo":      seoSchema,
+		"sections": map[string]interface{}{"type": "array"},
+	},
+}
+
+var navItemSchema = map[string]interface{}{
+	"type":                 "object",
+	"required":             []interface{}{"label"},
+	"additionalProperties": false,
+	"properties": map[string]interface{}{
+		"label":  map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 60},
+		"labels": map[string]interface{}{"type": "object"},
+		"pageId": map[string]interface{}{"type": "string"},
+		"url":    map[string]interface{}{"type": "string", "format": "uri-reference"},
+	},
+}
+
+var navSchema = map[string]interface{}{
+	"type":     "array",
+	"maxItems": 20,
+	"items":    withChildren(navItemSchema, map[string]interface{}{"type": "array", "maxItems": 20, "items": navItemSchema}),
+}
+
+func withChildren(item map[string]interface{}, children map[string]interface{}) map[string]interface{} {
+	props := map[string]interface{}{"children": children}
+	for key, value := range item["properties"].(map[string]interface{}) {
+		props[key] = value
+	}
+	out := map[string]interface{}{}
+	for key, value := range item {
+		out[key] = value
+	}
+	out["properties"] = props
+	return out
+}
+
+// contentSchema bounds the top level of a content document. Navigation is
+// checked on its own so that its links can be resolved against the pages.
+var contentSchema = map[string]interface{}{
+	"type":                 "object",
+	"required":             []interface{}{"pages"},
+	"additionalProperties": false,
+	"properties": map[string]interface{}{
+		"pages":      map[string]interface{}{"type": "array", "maxItems": MaxPages},
+		"navigation": map[string]interface{}{},
+	},
+}
+
+// ValidateContent checks the pages, their sections and the navigation of a
+// content document. Single-page content must go through Normalize first.
+// Content is expected to hold plain JSON values, as produced by encoding/json.
+func ValidateContent(content map[string]interface{}) []sections.ValidationError {
+	errs := sections.Validate(contentSchema, content, "")
+	list, ok := content["pages"].([]interface{})
+	if !ok || len(list) > MaxPages {
+		return errs
+	}
+
+	ids := map[string]bool{}
+	paths := map[string]bool{}
+	for i, item := range list {
+		base := "/pages/" + strconv.Itoa(i)
+		errs = append(errs, sections.Validate(pageSchema, item, base)...)
+		page, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if id, ok := page["id"].(string); ok {
+			if ids[id] {
+				errs = append(errs, sections.ValidationError{Path: base + "/id", Message: "must be unique"})
+			}
+			ids[id] = true
+		}
+		if path, ok := page["path"].(string); ok {
+			if paths[path] {
+				errs = append(errs, sections.ValidationError{Path: base + "/path", Message: "must be unique"})
+			}
+			paths[path] = true
+		}
+		if list, ok := page["sections"].([]interface{}); ok {
+			errs = append(errs, sections.ValidateSections(list, base+"/sections")...)
+		}
+		if translations, ok := page["translations"].(map[string]interface{}); ok {
+			errs = append(errs, validateTranslations(translations, base+"/translations")...)
+		}
+	}
+	if len(list) > 0 && !paths[HomePath] {
+		errs = append(errs, sections.ValidationError{Path: "/pages", Message: "must contain a home page with path /"})
+	}
+
+	if nav, present := content["navigation"]; present {
+		navErrs := sections.Validate(navSchema, nav, "/navigation")
+		if len(navErrs) > 0 {
+			return append(errs, navErrs...)
+		}
+		errs = append(errs, validateLinks(nav.([]interface{}), "/navigation", ids)...)
+	}
+	return errs
+}
+
+func validateTranslations(translations map[string]interface{}, base string) []sections.ValidationError {
+	var errs []sections.ValidationError
+	for _, locale := range sortedKeys(translations) {
+		path := base + "/" + locale
+		if !locales.Valid(locale) {
+			errs = append(errs, sections.ValidationError{Path: path, Message: "must be keyed by a locale such as en or pt-BR"})
+			continue
+		}
+		errs = append(errs, sections.Validate(translationSchema, translations[locale], path)...)
+		if t, ok := translations[locale].(map[string]interface{}); ok {
+			if list, ok := t["sections"].([]interface{}); ok {
+				errs = append(errs, sections.ValidateSections(list, path+"/sections")...)
+			}
+		}
+	}
+	return errs
+}
+
+// validateLinks checks that every menu entry has exactly one target, that
+// page targets exist and that translated labels are keyed by locale.
+func validateLinks(items []interface{}, base string, ids map[string]bool) []sections.ValidationError {
+	var errs []sections.ValidationError
+	for i, raw := range items {
+		path := base + "/" + strconv.Itoa(i)
+		item := raw.(map[string]interface{})
+		pageID, hasPage := item["pageId"].(string)
+		_, hasURL := item["url"]
+		switch {
+		case hasPage == hasURL:
+			errs = append(errs, sections.ValidationError{Path: path, Message: "must have exactly one of pageId and url"})
+		case hasPage && !ids[pageID]:
+			errs = append(errs, sections.ValidationError{Path: path + "/pageId", Message: fmt.Sprintf("unknown page %q", pageID)})
+		}
+		if labels, ok := item["labels"].(map[string]interface{}); ok {
+			for _, locale := range sortedKeys(labels) {
+				label, isString := labels[locale].(string)
+				switch {
+				case !locales.Valid(locale):
+					errs = append(errs, sections.ValidationError{Path: path + "/labels/" + locale, Message: "must be keyed by a locale such as en or pt-BR"})
+				case !isString || label == "" || utf8.RuneCountInString(label) > 60:
+					errs = append(errs, sections.ValidationError{Path: path + "/labels/" + locale, Message: "must be a string of 1 to 60 characters"})
+				}
+			}
+		}
+		if children, ok := item["children"].([]interface{}); ok {
+			errs = append(errs, validateLinks(children, path+"/children", ids)...)
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package pages
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
+
+func content(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []sections.ValidationError
+	}{
+		{
+			name:    "valid",
+			content: `{"pages": [{"id": "home", "path": "/", "title": "Home", "sections": []}], "navigation": [{"label": "Home", "pageId": "home"}]}`,
+		},
+		{
+			name:    "title too long",
+			content: `{"pages": [{"id": "home", "path": "/", "title": "` + strings.Repeat("t", 121) + `"}]}`,
+			want:    []sections.ValidationError{{Path: "/pages/0/title", Message: "must be at most 120 characters"}},
+		},
+		{
+			name:    "empty",
+			content: `{}`,
+			want:    []sections.ValidationError{{Path: "/pages", Message: "is required"}},
+		},
+		{
+			name:    "unknown field",
+			content: `{"pages": [{"id": "home", "path": "/"}], "foo": 1}`,
+			want:    []sections.ValidationError{{Path: "/foo", Message: "is not allowed"}},
+		},
+		{
+			name:    "single-page shape",
+			content: `{"sections": []}`,
+			want: []sections.ValidationError{
+				{Path: "/pages", Message: "is required"},
+				{Path: "/sections", Message: "is not allowed"},
+			},
+		},
+		{
+			name:    "pages not an array",
+			content: `{"pages": {"id": "home"}}`,
+			want:    []sections.ValidationError{{Path: "/pages", Message: "must be of type array"}},
+		},
+		{
+			name:    "no home page",
+			content: `{"pages": [{"id": "about", "path": "/about"}]}`,
+			want:    []sections.ValidationError{{Path: "/pages", Message: "must contain a home page with path /"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateContent(content(t, tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedSinglePageIsValid(t *testing.T) {
+	normalized := Normalize(content(t, `{"sections": []}`))
+	if errs := ValidateContent(content(t, mustJSON(t, normalized))); len(errs) > 0 {
+		t.Fatalf("normalized content is invalid: %v", errs)
+	}
+}
+
+func TestParseRejectsMalformedPages(t *testing.T) {
+	if _, err := Parse(content(t, `{"pages": [{"id": "home", "path": "/", "title": 1}]}`)); err == nil {
+		t.Fatal("Parse accepted a numeric title")
+	}
+	doc, err := Parse(content(t, `{"sections": [{"id": "s1"}]}`))
+	if err != nil {
+		t.Fatalf("Parse of single-page content: %v", err)
+	}
+	if home, ok := doc.Find("/"); !ok || len(home.Sections) != 1 {
+		t.Fatalf("single-page content parsed as %+v", doc)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
@@ -0,0 +1,48 @@
+package publishing
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Publish snapshots the draft content of the site matching filter and makes it
+// public. A pending scheduled publish is cleared since it has been applied, as
+// is the record of an earlier one that was missed.
+// Sites with a review workflow only match once their current version is
+// approved, and archived or deleted sites never match.
+func Publish(ctx context.Context, sites *mongo.Collection, filter bson.M, now time.Time) (*mongo.UpdateResult, error) {
+	filter = bson.M{"$and": bson.A{filter, bson.M{"archivedAt": nil, "deletedAt": nil}, bson.M{"$or": bson.A{
+		bson.M{"workflow.enabled": bson.M{"$ne": true}},
+		bson.M{"workflow.state": "approved", "$expr": bson.M{"$eq": bson.A{"$workflow.approvedVersion", "$version"}}},
+	}}}}
+	// Pipeline update so the snapshot is copied from the stored draft atomically.
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":                "published",
+			"publishedContent":      "$content",
+			"hasUnpublishedChanges": false,
+			"publishedAt":           now,
+			"publishAt":             "$$REMOVE",
+			"publishMissed":         "$$REMOVE",
+			"updatedAt":             now,
+		}}},
+	}
+	return sites.UpdateOne(ctx, filter, update)
+}
+
+// Unpublish takes the site matching filter offline. The published snapshot is
+// kept so the draft can still be discarded back to it.
+func Unpublish(ctx context.Context, sites *mongo.Collection, filter bson.M, now time.Time) (*mongo.UpdateResult, error) {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":      "draft",
+			"publishedAt": nil,
+			"unpublishAt": "$$REMOVE",
+			"updatedAt":   now,
+		}}},
+	}
+	return sites.UpdateOne(ctx, filter, update)
+}
@@ -0,0 +1,114 @@
+// Package render turns site content into complete HTML pages using the
+// built-in theme. Every section type has a template named
+// "section-<type>"; sections without one are skipped.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io"
+	"strings"
+)
+
+//go:embed templates/*.html templates/theme.css
+var files embed.FS
+
+var (
+	pages    = template.Must(parse())
+	themeCSS = template.CSS(mustRead("templates/theme.css"))
+)
+
+// Section is one content section with its data as plain JSON values.
+type Section struct {
+	Type string                 `json:"type"`
+	ID   string                 `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type Page struct {
+	Title       string
+	Description string
+	Image       string
+	Favicon     string
+	Lang        string
+	RTL         bool
+	NoIndex     bool
+	Nav         []NavLink
+	Sections    []Section
+}
+
+// NavLink is a resolved menu entry. Active marks the link to the page being
+// rendered.
+type NavLink struct {
+	Label    string
+	Href     string
+	Active   bool
+	Children []NavLink
+}
+
+// Sections converts the sections of a page into their typed form.
+func Sections(list []map[string]interface{}) []Section {
+	raw, err := json.Marshal(list)
+	if err != nil {
+		return nil
+	}
+	var out []Section
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// Render writes page as a complete HTML document.
+func Render(w io.Writer, page Page) error {
+	if page.Lang == "" {
+		page.Lang = "tr"
+	}
+	return pages.ExecuteTemplate(w, "page", struct {
+		Page
+		CSS template.CSS
+	}{page, themeCSS})
+}
+
+func parse() (*template.Template, error) {
+	var t *template.Template
+	t = template.New("").Option("missingkey=zero").Funcs(template.FuncMap{
+		// section renders s with its type's template. The result was
+		// produced by html/template, so it is already escaped.
+		"section": func(s Section) (template.HTML, error) {
+			st := t.Lookup("section-" + s.Type)
+			if st == nil {
+				return "", nil
+			}
+			var buf bytes.Buffer
+			if err := st.Execute(&buf, s); err != nil {
+				return "", err
+			}
+			return template.HTML(buf.String()), nil
+		},
+		"paragraphs": paragraphs,
+	})
+	return t.ParseFS(files, "templates/*.html")
+}
+
+// paragraphs splits plain text on blank lines.
+func paragraphs(text interface{}) []string {
+	s, _ := text.(string)
+	var out []string
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func mustRead(name string) string {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
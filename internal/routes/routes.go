@@ -1,18 +1,25 @@
 package routes
 
 import (
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/config"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/domains"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/forms"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/handlers"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/imaging"
 	"github.com/youpp/youpp-website-adminpanel-backend/internal/middleware"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sitecache"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/storage"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config) {
+func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config, bus *events.Bus, store storage.Storage) {
 	frontendOrigins := cfg.FrontendOrigins
 	if len(frontendOrigins) == 0 {
 		frontendOrigins = []string{
@@ -28,23 +35,37 @@ func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config)
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     frontendOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-API-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-API-Key", "If-Match", "If-None-Match", "If-Modified-Since"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Last-Modified", "Content-Language"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	authHandler := &handlers.AuthHandler{Users: db.Collection("users"), Cfg: cfg}
-	publicAuthHandler := &handlers.PublicAuthHandler{Users: db.Collection("users"), Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Cfg: cfg}
-	siteHandler := &handlers.SiteHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions")}
-	adminHandler := &handlers.AdminHandler{Users: db.Collection("users"), Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions")}
+	publicAuthHandler := &handlers.PublicAuthHandler{Users: db.Collection("users"), Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), SlugRedirects: db.Collection("slug_redirects"), Templates: db.Collection("templates"), Cfg: cfg}
+	siteHandler := &handlers.SiteHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), SiteReviews: db.Collection("site_reviews"), SlugRedirects: db.Collection("slug_redirects"), Templates: db.Collection("templates"), Locks: db.Collection("site_locks"), Events: bus, TrashRetention: time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour}
+	adminHandler := &handlers.AdminHandler{Users: db.Collection("users"), Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), SlugRedirects: db.Collection("slug_redirects"), Templates: db.Collection("templates"), Events: bus}
 	provisionHandler := &handlers.ProvisionHandler{Cfg: cfg, Users: db.Collection("users")}
-	publicHandler := &handlers.PublicHandler{Sites: db.Collection("sites")}
+	previewHandler := &handlers.PreviewHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), PreviewTokens: db.Collection("preview_tokens")}
+	commentHandler := &handlers.CommentHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Users: db.Collection("users"), CommentThreads: db.Collection("comment_threads")}
+	lockHandler := &handlers.LockHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Users: db.Collection("users"), Locks: db.Collection("site_locks"), Events: bus}
+	eventHandler := &handlers.EventHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Users: db.Collection("users"), Presence: db.Collection("site_presence"), StreamTickets: db.Collection("stream_tickets"), Events: bus}
+	siteCache := sitecache.New(cfg.PublicCacheEntries, time.Duration(cfg.PublicCacheTTLSec)*time.Second)
+	siteCache.Follow(bus)
+	formLimiter := &forms.Limiter{Hits: db.Collection("form_rate_limits"), Max: forms.RateLimit, Window: forms.RateWindow}
+	publicHandler := &handlers.PublicHandler{Sites: db.Collection("sites"), PreviewTokens: db.Collection("preview_tokens"), SlugRedirects: db.Collection("slug_redirects"), Domains: db.Collection("site_domains"), Forms: db.Collection("forms"), Submissions: db.Collection("form_submissions"), BaseURL: cfg.PublicBaseURL, Cache: siteCache, CacheControl: cfg.PublicCacheControl, FormLimiter: formLimiter, NotifyForms: cfg.SMTPHost != ""}
+	sectionTypeHandler := &handlers.SectionTypeHandler{}
+	templateHandler := &handlers.TemplateHandler{Templates: db.Collection("templates")}
+	domainHandler := &handlers.DomainHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Domains: db.Collection("site_domains"), Verifier: &domains.Verifier{Domains: db.Collection("site_domains"), Resolver: net.DefaultResolver, PendingTimeout: domains.DefaultPendingTimeout}, PlatformDomains: cfg.PlatformDomains}
+	webhookHandler := &handlers.WebhookHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Webhooks: db.Collection("webhooks"), Deliveries: db.Collection("webhook_deliveries")}
+	formHandler := &handlers.FormHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Forms: db.Collection("forms"), Submissions: db.Collection("form_submissions")}
+	assetHandler := &handlers.AssetHandler{Sites: db.Collection("sites"), SitePermissions: db.Collection("site_permissions"), Assets: db.Collection("assets"), Storage: store, Cache: &imaging.DiskCache{Dir: cfg.ImageCacheDir}, MaxUploadBytes: int64(cfg.AssetMaxUploadMB) << 20, BaseURL: cfg.PublicBaseURL}
 
 	api := router.Group("/api")
 	{
 		public := api.Group("/public")
 		public.POST("/register", publicAuthHandler.Register)
+		public.GET("/templates", templateHandler.ListPublic)
 
 		auth := api.Group("/auth")
 		auth.POST("/login", authHandler.Login)
@@ -55,15 +76,87 @@ func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config)
 		provision.POST("/bootstrap", provisionHandler.Bootstrap)
 
 		api.GET("/me", middleware.AuthRequired(cfg.JWTSecret), authHandler.Me)
+		api.GET("/sites/:id/events", middleware.StreamAuthRequired(cfg.JWTSecret, db.Collection("stream_tickets")), siteHandler.RequireSite, eventHandler.Stream)
 
 		secured := api.Group("")
 		secured.Use(middleware.AuthRequired(cfg.JWTSecret))
+		secured.GET("/section-types", sectionTypeHandler.List)
 		secured.GET("/sites", siteHandler.List)
 		secured.POST("/sites", siteHandler.Create)
-		secured.GET("/sites/:id", siteHandler.Get)
-		secured.PUT("/sites/:id/content", siteHandler.UpdateContent)
-		secured.POST("/sites/:id/publish", siteHandler.Publish)
-		secured.POST("/sites/:id/unpublish", siteHandler.Unpublish)
+		secured.GET("/sites/trash", siteHandler.ListTrash)
+		secured.POST("/sites/import", siteHandler.Import)
+		secured.POST("/sites/:id/restore", siteHandler.Restore)
+
+		site := secured.Group("/sites/:id", siteHandler.RequireSite)
+		site.GET("", siteHandler.Get)
+		site.DELETE("", siteHandler.Delete)
+		site.POST("/archive", siteHandler.Archive)
+		site.POST("/unarchive", siteHandler.Unarchive)
+		site.POST("/clone", siteHandler.Clone)
+		site.GET("/export", siteHandler.Export)
+		site.GET("/reviews", siteHandler.ListReviews)
+		site.POST("/events/ticket", eventHandler.Ticket)
+		site.GET("/locks", lockHandler.List)
+		site.DELETE("/locks", lockHandler.Release)
+		site.GET("/comments", commentHandler.List)
+		site.GET("/previews", previewHandler.List)
+		site.DELETE("/previews/:previewId", previewHandler.Revoke)
+		site.GET("/assets", assetHandler.List)
+		site.GET("/domains", domainHandler.List)
+		site.GET("/settings", siteHandler.GetSettings)
+		site.GET("/pages", siteHandler.ListPages)
+		site.GET("/pages/:pageId", siteHandler.GetPage)
+		site.DELETE("/domains/:domainId", domainHandler.Delete)
+		site.GET("/webhooks", webhookHandler.List)
+		site.DELETE("/webhooks/:webhookId", webhookHandler.Delete)
+		site.GET("/webhooks/:webhookId/deliveries", webhookHandler.ListDeliveries)
+		site.POST("/webhooks/:webhookId/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+		site.GET("/forms", formHandler.List)
+		site.GET("/forms/:formId", formHandler.Get)
+		site.DELETE("/forms/:formId", formHandler.Delete)
+		site.GET("/forms/:formId/export", formHandler.Export)
+		site.GET("/submissions", formHandler.ListSubmissions)
+		site.PATCH("/submissions/:submissionId", formHandler.UpdateSubmission)
+		site.DELETE("/submissions/:submissionId", formHandler.DeleteSubmission)
+
+		// Archived sites are read-only.
+		writable := site.Group("", siteHandler.RequireWritableSite)
+		writable.PUT("/slug", siteHandler.ChangeSlug)
+		writable.PUT("/content", siteHandler.UpdateContent)
+		writable.PATCH("/content", siteHandler.PatchContent)
+		writable.POST("/publish", siteHandler.Publish)
+		writable.POST("/unpublish", siteHandler.Unpublish)
+		writable.PUT("/schedule/publish", siteHandler.SchedulePublish)
+		writable.DELETE("/schedule/publish", siteHandler.CancelPublish)
+		writable.PUT("/schedule/unpublish", siteHandler.ScheduleUnpublish)
+		writable.DELETE("/schedule/unpublish", siteHandler.CancelUnpublish)
+		writable.POST("/discard", siteHandler.DiscardDraft)
+		writable.PUT("/workflow", siteHandler.UpdateWorkflow)
+		writable.PUT("/locales", siteHandler.UpdateLocales)
+		writable.PUT("/settings", siteHandler.UpdateSettings)
+		writable.POST("/submit", siteHandler.SubmitForReview)
+		writable.POST("/approve", siteHandler.ApproveReview)
+		writable.POST("/reject", siteHandler.RejectReview)
+		writable.PUT("/locks", lockHandler.Acquire)
+		writable.POST("/comments", commentHandler.Create)
+		writable.POST("/comments/:threadId/replies", commentHandler.Reply)
+		writable.POST("/comments/:threadId/resolve", commentHandler.Resolve)
+		writable.POST("/comments/:threadId/reopen", commentHandler.Reopen)
+		writable.POST("/previews", previewHandler.Create)
+		writable.POST("/assets", assetHandler.Upload)
+		writable.PATCH("/assets/:assetId", assetHandler.Update)
+		writable.DELETE("/assets/:assetId", assetHandler.Delete)
+		writable.POST("/domains", domainHandler.Create)
+		writable.POST("/domains/:domainId/verify", domainHandler.Verify)
+		writable.POST("/pages", siteHandler.CreatePage)
+		writable.PUT("/pages/:pageId", siteHandler.UpdatePage)
+		writable.DELETE("/pages/:pageId", siteHandler.DeletePage)
+		writable.PUT("/navigation", siteHandler.UpdateNavigation)
+		writable.POST("/webhooks", webhookHandler.Create)
+		writable.PUT("/webhooks/:webhookId", webhookHandler.Update)
+		writable.POST("/webhooks/:webhookId/secret", webhookHandler.RotateSecret)
+		writable.POST("/forms", formHandler.Create)
+		writable.PUT("/forms/:formId", formHandler.Update)
 
 		admin := api.Group("/admin")
 		admin.Use(middleware.AuthRequired(cfg.JWTSecret), middleware.SuperAdminRequired())
@@ -73,6 +166,10 @@ func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config)
 		admin.GET("/sites/:id/users", adminHandler.ListSiteUsers)
 		admin.POST("/users", adminHandler.CreateUser)
 		admin.GET("/users", adminHandler.ListUsers)
+		admin.GET("/templates", templateHandler.List)
+		admin.POST("/templates", templateHandler.Create)
+		admin.PUT("/templates/:id", templateHandler.Update)
+		admin.DELETE("/templates/:id", templateHandler.Delete)
 	}
 
 	router.GET("/health", func(c *gin.Context) {
@@ -80,4 +177,9 @@ func RegisterRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config)
 	})
 
 	router.GET("/s/:slug", publicHandler.GetPublishedSite)
+	router.GET("/s/:slug/*path", publicHandler.GetPublishedPage)
+	router.POST("/s/:slug/forms/:formId", publicHandler.SubmitForm)
+	router.GET("/assets/:name", assetHandler.Serve)
+	// Requests for custom domains fall through to here.
+	router.NoRoute(publicHandler.ServeCustomDomain)
 }
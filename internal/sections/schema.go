@@ -0,0 +1,178 @@
+package sections
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError points at a value that does not satisfy its schema. Path is
+// an RFC 6901 JSON Pointer into the validated document.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Validate checks value, found at the JSON Pointer path, against the subset of
+// JSON Schema used by section types: type, enum, properties, required, additionalProperties, items,
+// min/maxItems, min/maxLength, pattern, format, minimum and maximum.
+func Validate(schema map[string]interface{}, value interface{}, path string) []ValidationError {
+	var errs []ValidationError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if expected, ok := schema["type"].(string); ok && !hasType(value, expected) {
+		fail("must be of type %s", expected)
+		return errs
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		fail("must be one of the allowed values")
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min, ok := number(schema["minLength"]); ok && float64(length) < min {
+			fail("must be at least %d characters", int(min))
+		}
+		if max, ok := number(schema["maxLength"]); ok && float64(length) > max {
+			fail("must be at most %d characters", int(max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+		if format, ok := schema["format"].(string); ok && !matchesFormat(format, v) {
+			fail("must be a valid %s", format)
+		}
+	case float64:
+		if min, ok := number(schema["minimum"]); ok && v < min {
+			fail("must be at least %v", min)
+		}
+		if max, ok := number(schema["maximum"]); ok && v > max {
+			fail("must be at most %v", max)
+		}
+	case []interface{}:
+		if min, ok := number(schema["minItems"]); ok && float64(len(v)) < min {
+			fail("must contain at least %d items", int(min))
+		}
+		if max, ok := number(schema["maxItems"]); ok && float64(len(v)) > max {
+			fail("must contain at most %d items", int(max))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, Validate(items, item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				key, _ := name.(string)
+				if _, present := v[key]; !present {
+					errs = append(errs, ValidationError{Path: path + "/" + escapePointer(key), Message: "is required"})
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := path + "/" + escapePointer(key)
+			propSchema, known := properties[key].(map[string]interface{})
+			if !known {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					errs = append(errs, ValidationError{Path: childPath, Message: "is not allowed"})
+				}
+				continue
+			}
+			errs = append(errs, Validate(propSchema, v[key], childPath)...)
+		}
+	}
+	return errs
+}
+
+func hasType(value interface{}, expected string) bool {
+	switch expected {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, candidate := range enum {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// number reads a numeric keyword. Schemas decoded from JSON hold float64;
+// schemas written in Go usually hold int.
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "uri-reference":
+		_, err := url.Parse(value)
+		return err == nil && !strings.ContainsAny(value, " \t\n")
+	case "email":
+		at := strings.LastIndex(value, "@")
+		return at > 0 && at < len(value)-1 && !strings.ContainsAny(value, " \t\n")
+	case "date":
+		_, err := time.Parse(time.DateOnly, value)
+		return err == nil
+	}
+	return true
+}
+
+func escapePointer(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
@@ -0,0 +1,106 @@
+// Package sections holds the registry of content section types and validates
+// site content against their schemas.
+package sections
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// Type describes a section that can appear in site content. Schema is a JSON
+// Schema for the section's data object. Version is bumped whenever the schema
+// changes incompatibly.
+type Type struct {
+	Name    string                 `json:"name"`
+	Version int                    `json:"version"`
+	Title   string                 `json:"title"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+//go:embed types/*.json
+var typeFiles embed.FS
+
+var registry = mustLoadTypes()
+
+func mustLoadTypes() map[string]Type {
+	entries, err := typeFiles.ReadDir("types")
+	if err != nil {
+		panic(err)
+	}
+	types := make(map[string]Type, len(entries))
+	for _, entry := range entries {
+		raw, err := typeFiles.ReadFile(path.Join("types", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var t Type
+		if err := json.Unmarshal(raw, &t); err != nil {
+			panic(fmt.Sprintf("section type %s: %v", entry.Name(), err))
+		}
+		types[t.Name] = t
+	}
+	return types
+}
+
+// All returns every registered section type ordered by name.
+func All() []Type {
+	out := make([]Type, 0, len(registry))
+	for _, t := range registry {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Lookup returns the section type with the given name.
+func Lookup(name string) (Type, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// ValidateSections checks a list of sections found at the JSON Pointer base.
+// The list is expected to hold plain JSON values, as produced by
+// encoding/json.
+func ValidateSections(raw interface{}, base string) []ValidationError {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return []ValidationError{{Path: base, Message: "must be of type array"}}
+	}
+
+	var errs []ValidationError
+	for i, item := range list {
+		path := base + "/" + strconv.Itoa(i)
+		section, ok := item.(map[string]interface{})
+		if !ok {
+			errs = append(errs, ValidationError{Path: path, Message: "must be of type object"})
+			continue
+		}
+		name, _ := section["type"].(string)
+		t, known := Lookup(name)
+		if !known {
+			errs = append(errs, ValidationError{Path: path + "/type", Message: fmt.Sprintf("unknown section type %q", name)})
+			continue
+		}
+		if id, present := section["id"]; present {
+			if _, ok := id.(string); !ok {
+				errs = append(errs, ValidationError{Path: path + "/id", Message: "must be of type string"})
+			}
+		}
+		if version, present := section["version"]; present {
+			if n, ok := version.(float64); !ok || n != float64(int(n)) || int(n) < 1 || int(n) > t.Version {
+				errs = append(errs, ValidationError{Path: path + "/version", Message: fmt.Sprintf("must be an integer between 1 and %d", t.Version)})
+			}
+		}
+		data, present := section["data"]
+		if !present {
+			errs = append(errs, ValidationError{Path: path + "/data", Message: "is required"})
+			continue
+		}
+		errs = append(errs, Validate(t.Schema, data, path+"/data")...)
+	}
+	return errs
+}
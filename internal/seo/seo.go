@@ -0,0 +1,199 @@
+// Package seo generates the crawler-facing files of a published site:
+// sitemap.xml, robots.txt and an RSS feed of its dated posts.
+package seo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/pages"
+)
+
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name        `xml:"urlset"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	URLs    []sitemapURLTag `xml:"url"`
+}
+
+type sitemapURLTag struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap renders urls as a sitemaps.org urlset.
+func Sitemap(urls []URL) ([]byte, error) {
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, u := range urls {
+		tag := sitemapURLTag{Loc: u.Loc}
+		if !u.LastMod.IsZero() {
+			tag.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, tag)
+	}
+	return marshalXML(set)
+}
+
+// Robots renders robots.txt. Indexable sites allow crawling except for
+// preview links and point at their sitemap; the rest disallow everything.
+func Robots(indexable bool, sitemapURL string) []byte {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if !indexable {
+		b.WriteString("Disallow: /\n")
+		return []byte(b.String())
+	}
+	b.WriteString("Disallow: /*?preview=\n")
+	b.WriteString("Disallow: /*&preview=\n")
+	b.WriteString("Allow: /\n")
+	if sitemapURL != "" {
+		b.WriteString("\nSitemap: " + sitemapURL + "\n")
+	}
+	return []byte(b.String())
+}
+
+// Post is a dated content section. Link is the URL of the page holding it.
+type Post struct {
+	ID      string
+	Title   string
+	Summary string
+	Date    time.Time
+	Link    string
+}
+
+// Posts returns the post sections of every page of doc, newest first.
+// pageURL maps a page path to its absolute URL.
+func Posts(doc pages.Document, pageURL func(path string) string) []Post {
+	var posts []Post
+	for _, page := range doc.Pages {
+		raw, err := json.Marshal(page.Sections)
+		if err != nil {
+			continue
+		}
+		var list []struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+			Data struct {
+				Title   string `json:"title"`
+				Date    string `json:"date"`
+				Summary string `json:"summary"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			continue
+		}
+		for _, s := range list {
+			if s.Type != "post" {
+				continue
+			}
+			date, err := time.Parse(time.DateOnly, s.Data.Date)
+			if err != nil {
+				continue
+			}
+			posts = append(posts, Post{ID: s.ID, Title: s.Data.Title, Summary: s.Data.Summary, Date: date, Link: pageURL(page.Path)})
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].Date.After(posts[j].Date) })
+	return posts
+}
+
+type Channel struct {
+	Title   string
+	Link    string
+	FeedURL string
+	Updated time.Time
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// Feed renders posts as an RSS 2.0 feed. Posts link to their section anchor
+// on their page, or on the channel page when they have no link.
+func Feed(channel Channel, posts []Post) ([]byte, error) {
+	feed := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       channel.Title,
+			Link:        channel.Link,
+			Description: channel.Title,
+			AtomLink:    atomLink{Href: channel.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !channel.Updated.IsZero() {
+		feed.Channel.LastBuildDate = channel.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, post := range posts {
+		link := post.Link
+		if link == "" {
+			link = channel.Link
+		}
+		if post.ID != "" {
+			link += "#" + post.ID
+		}
+		guid := link
+		if post.ID == "" {
+			guid = link + "#" + post.Date.Format(time.DateOnly) + "-" + post.Title
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        rssGUID{Value: guid, IsPermaLink: false},
+			PubDate:     post.Date.Format(time.RFC1123Z),
+			Description: post.Summary,
+		})
+	}
+	return marshalXML(feed)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+// Package settings validates the site-wide settings sub-document.
+package settings
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/sections"
+)
+
+// Image and icon references are absolute http(s) URLs or paths on the API
+// host, such as media library URLs.
+const imageRef = `^(https?://[^\s]+|/[^\s]*)$`
+
+var schema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"title":              map[string]interface{}{"type": "string", "maxLength": 120},
+		"description":        map[string]interface{}{"type": "string", "maxLength": 320},
+		"ogImage":            map[string]interface{}{"type": "string", "maxLength": 2048, "pattern": imageRef},
+		"favicon":            map[string]interface{}{"type": "string", "maxLength": 2048, "pattern": imageRef},
+		"googleAnalyticsId":  map[string]interface{}{"type": "string", "pattern": `^G-[A-Z0-9]{4,12}$`},
+		"googleTagManagerId": map[string]interface{}{"type": "string", "pattern": `^GTM-[A-Z0-9]{4,10}$`},
+		"contactEmail":       map[string]interface{}{"type": "string", "maxLength": 254, "format": "email"},
+	},
+}
+
+// Normalize trims every field of s.
+func Normalize(s models.SiteSettings) models.SiteSettings {
+	s.Title = strings.TrimSpace(s.Title)
+	s.Description = strings.TrimSpace(s.Description)
+	s.OGImage = strings.TrimSpace(s.OGImage)
+	s.Favicon = strings.TrimSpace(s.Favicon)
+	s.GoogleAnalyticsID = strings.ToUpper(strings.TrimSpace(s.GoogleAnalyticsID))
+	s.GoogleTagManagerID = strings.ToUpper(strings.TrimSpace(s.GoogleTagManagerID))
+	s.ContactEmail = strings.ToLower(strings.TrimSpace(s.ContactEmail))
+	return s
+}
+
+// Validate checks s. Empty fields are unset and always valid.
+func Validate(s models.SiteSettings) []sections.ValidationError {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return []sections.ValidationError{{Path: "", Message: err.Error()}}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return []sections.ValidationError{{Path: "", Message: err.Error()}}
+	}
+	return sections.Validate(schema, doc, "")
+}
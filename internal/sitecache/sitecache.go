@@ -0,0 +1,143 @@
+// Package sitecache keeps recently served published sites in memory. Entries
+// are dropped when their site changes, which every replica learns from the
+// event bus, and expire after a TTL in case an event is missed.
+package sitecache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cache is a bounded LRU of sites keyed by how they were looked up, such as
+// a slug. Cached sites are shared and must not be modified. A nil Cache
+// caches nothing.
+type Cache struct {
+	max int
+	ttl time.Duration
+
+	mu     sync.Mutex
+	ll     *list.List
+	items  map[string]*list.Element
+	bySite map[primitive.ObjectID]map[string]struct{}
+	epoch  uint64
+}
+
+type entry struct {
+	key     string
+	site    models.Site
+	expires time.Time
+}
+
+// New returns a cache holding at most max sites for ttl each. It returns nil
+// when max is not positive.
+func New(max int, ttl time.Duration) *Cache {
+	if max <= 0 {
+		return nil
+	}
+	return &Cache{
+		max:    max,
+		ttl:    ttl,
+		ll:     list.New(),
+		items:  map[string]*list.Element{},
+		bySite: map[primitive.ObjectID]map[string]struct{}{},
+	}
+}
+
+func (c *Cache) Get(key string) (models.Site, bool) {
+	if c == nil {
+		return models.Site{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return models.Site{}, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return models.Site{}, false
+	}
+	c.ll.MoveToFront(el)
+	return e.site, true
+}
+
+// Epoch returns a token to take before loading a site from the database and
+// to pass to Add afterwards.
+func (c *Cache) Epoch() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// Add stores site under key unless a site was invalidated since epoch was
+// taken: the loaded copy may predate the change that caused it.
+func (c *Cache) Add(key string, site models.Site, epoch uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	e := &entry{key: key, site: site, expires: time.Now().Add(c.ttl)}
+	c.items[key] = c.ll.PushFront(e)
+	if c.bySite[site.ID] == nil {
+		c.bySite[site.ID] = map[string]struct{}{}
+	}
+	c.bySite[site.ID][key] = struct{}{}
+	for c.ll.Len() > c.max {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Follow invalidates sites as the bus reports changes to them. Presence and
+// lock events leave the public site untouched.
+func (c *Cache) Follow(bus *events.Bus) {
+	if c == nil {
+		return
+	}
+	bus.Listen(func(event events.Event) {
+		if event.Type != events.Presence && event.Type != events.LocksChanged {
+			c.Invalidate(event.SiteID)
+		}
+	})
+}
+
+// Invalidate drops every entry of the site.
+func (c *Cache) Invalidate(siteID primitive.ObjectID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for key := range c.bySite[siteID] {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, e.key)
+	if keys := c.bySite[e.site.ID]; keys != nil {
+		delete(keys, e.key)
+		if len(keys) == 0 {
+			delete(c.bySite, e.site.ID)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package slugs checks site slug availability. A slug is taken when a site
+// uses it or when it is a retired slug that still redirects to its site.
+package slugs
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reserved reports whether slug was previously used by a site other than
+// exceptSiteID. Retired slugs keep redirecting to their site, so nobody else
+// may claim them.
+func Reserved(ctx context.Context, redirects *mongo.Collection, slug string, exceptSiteID primitive.ObjectID) (bool, error) {
+	filter := bson.M{"slug": slug}
+	if !exceptSiteID.IsZero() {
+		filter["siteId"] = bson.M{"$ne": exceptSiteID}
+	}
+	count, err := redirects.CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+// Available reports whether a new site may use slug.
+func Available(ctx context.Context, sites, redirects *mongo.Collection, slug string) (bool, error) {
+	count, err := sites.CountDocuments(ctx, bson.M{"slug": slug})
+	if err != nil || count > 0 {
+		return false, err
+	}
+	reserved, err := Reserved(ctx, redirects, slug, primitive.NilObjectID)
+	return !reserved, err
+}
+
+// ResolveUnique returns baseSlug, or baseSlug with the first free numeric
+// suffix.
+func ResolveUnique(ctx context.Context, sites, redirects *mongo.Collection, baseSlug string) (string, error) {
+	for i := 0; i <= 200; i++ {
+		slug := baseSlug
+		if i > 0 {
+			slug = fmt.Sprintf("%s-%d", baseSlug, i)
+		}
+		available, err := Available(ctx, sites, redirects, slug)
+		if err != nil {
+			return "", err
+		}
+		if available {
+			return slug, nil
+		}
+	}
+	return "", fmt.Errorf("unable to resolve unique slug")
+}
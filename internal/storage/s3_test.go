@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-central-1"
+)
+
+type receivedRequest struct {
+	method        string
+	path          string
+	authorization string
+	wantAuth      string
+	contentType   string
+	body          string
+}
+
+// fakeS3 answers like a bucket holding no objects except what was put, and
+// records each request with the Authorization a SigV4 verifier would expect.
+func fakeS3(t *testing.T) (*S3, *[]receivedRequest) {
+	t.Helper()
+	var received []receivedRequest
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, receivedRequest{
+			method:        r.Method,
+			path:          r.URL.EscapedPath(),
+			authorization: r.Header.Get("Authorization"),
+			wantAuth:      expectedAuthorization(r),
+			contentType:   r.Header.Get("Content-Type"),
+			body:          string(body),
+		})
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			if r.URL.Path == "/bucket/broken" {
+				http.Error(w, "<Error><Code>InternalError</Code></Error>", http.StatusInternalServerError)
+				return
+			}
+			object, ok := objects[r.URL.Path]
+			if !ok {
+				http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+				return
+			}
+			_, _ = io.WriteString(w, object)
+		case http.MethodDelete:
+			if _, ok := objects[r.URL.Path]; !ok {
+				http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+				return
+			}
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3(server.URL+"/", testRegion, "bucket", testAccessKey, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, &received
+}
+
+// expectedAuthorization recomputes the SigV4 Authorization header for a
+// request as received, following the AWS documentation step by step.
+func expectedAuthorization(r *http.Request) string {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) < 8 {
+		return ""
+	}
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + r.Header.Get("X-Amz-Content-Sha256") + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		"UNSIGNED-PAYLOAD"
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac(mac(mac(mac([]byte("AWS4"+testSecretKey), amzDate[:8]), testRegion), "s3"), "aws4_request")
+	return "AWS4-HMAC-SHA256 Credential=" + testAccessKey + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=" + hex.EncodeToString(mac(key, stringToSign))
+}
+
+func TestS3SignsRequests(t *testing.T) {
+	s3, received := fakeS3(t)
+	ctx := context.Background()
+	key := "sites/a b/ümlaut+1.png"
+	if err := s3.Put(ctx, key, strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := s3.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "png" {
+		t.Fatalf("Open returned %q, want %q", data, "png")
+	}
+
+	if len(*received) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(*received))
+	}
+	for _, req := range *received {
+		if req.path != "/bucket/sites/a%20b/%C3%BCmlaut%2B1.png" {
+			t.Errorf("%s path = %q, want every reserved byte but / escaped", req.method, req.path)
+		}
+		if req.authorization == "" || req.authorization != req.wantAuth {
+			t.Errorf("%s Authorization =\n  %q\nwant\n  %q", req.method, req.authorization, req.wantAuth)
+		}
+	}
+	if put := (*received)[0]; put.contentType != "image/png" || put.body != "png" {
+		t.Errorf("PUT sent %q as %q", put.body, put.contentType)
+	}
+}
+
+// TestS3SignatureKnownDate pins the signature for a fixed request. The
+// expected value was computed with a separate SigV4 implementation, so a
+// mistake shared by the signer and expectedAuthorization still shows up.
+func TestS3SignatureKnownDate(t *testing.T) {
+	s3 := &S3{Endpoint: "https://s3.example.com", Region: testRegion, Bucket: "bucket", AccessKey: testAccessKey, SecretKey: testSecretKey}
+	req, err := s3.newRequest(context.Background(), http.MethodGet, "a/b.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3.sign(req, time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	if got := req.Header.Get("X-Amz-Date"); got != "20240501T123000Z" {
+		t.Fatalf("X-Amz-Date = %q", got)
+	}
+	const want = "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240501/eu-central-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=29a5119c161db397de4a4fb87314cb092824129ee7319c0e54c791115dbadc64"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization =\n  %q\nwant\n  %q", got, want)
+	}
+}
+
+func TestS3NotFound(t *testing.T) {
+	s3, _ := fakeS3(t)
+	ctx := context.Background()
+
+	if _, err := s3.Open(ctx, "missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open of a missing key: got %v, want ErrNotFound", err)
+	}
+	if err := s3.Delete(ctx, "missing.png"); err != nil {
+		t.Fatalf("Delete of a missing key: got %v, want nil", err)
+	}
+	_, err := s3.Open(ctx, "broken")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "InternalError") {
+		t.Fatalf("Open answered 500: got %v, want an error carrying the response", err)
+	}
+}
+
+func TestS3RejectsInvalidKeys(t *testing.T) {
+	s3, received := fakeS3(t)
+	for _, key := range []string{"", "/abs", "a/../b", "a//b", `a\b`} {
+		if _, err := s3.Open(context.Background(), key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q): got %v, want an invalid key error", key, err)
+		}
+	}
+	if len(*received) != 0 {
+		t.Fatalf("invalid keys reached the server %d times", len(*received))
+	}
+}
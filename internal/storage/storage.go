@@ -0,0 +1,35 @@
+// Package storage stores asset bytes behind a small interface so the API can
+// run against the local filesystem or an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage is a flat key/value blob store. Keys are slash-separated paths
+// chosen by the caller.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns ErrNotFound when the key does not exist.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds when the key does not exist.
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey rejects keys that could escape the storage root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,325 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// QueuedField marks site events whose deliveries have been created.
+	QueuedField = "webhooksQueued"
+
+	requestTimeout = 10 * time.Second
+	// claimTimeout keeps a delivery from other senders while its request is
+	// in flight. It must outlast requestTimeout.
+	claimTimeout  = time.Minute
+	batchSize     = 50
+	senders       = 4
+	responseLimit = 1024
+	userAgent     = "Youpp-Webhooks/1.0"
+)
+
+// Dispatcher turns site events into deliveries and sends them.
+type Dispatcher struct {
+	Events     *mongo.Collection
+	Webhooks   *mongo.Collection
+	Deliveries *mongo.Collection
+	Client     *http.Client
+}
+
+// NewClient returns the HTTP client deliveries are sent with. It does not
+// follow redirects and, unless allowPrivate is set, refuses to connect to
+// loopback, private and link-local addresses so webhooks cannot reach
+// internal services.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("refusing to connect to %s", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second, MaxIdleConnsPerHost: 2},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Queue creates a pending delivery of event for every active webhook of the
+// site subscribed to it, then marks the event queued. It is called as events
+// are published, so deliveries are stored even when no dispatcher runs for
+// longer than events are retained.
+func (d *Dispatcher) Queue(ctx context.Context, event events.Event) error {
+	if !ValidEvent(event.Type) {
+		return nil
+	}
+	if err := d.enqueue(ctx, event); err != nil {
+		return err
+	}
+	if _, err := d.Events.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": bson.M{QueuedField: true}}); err != nil {
+		return fmt.Errorf("mark event %s queued: %w", event.ID.Hex(), err)
+	}
+	return nil
+}
+
+// Enqueue queues the events that Queue failed on, or that were published
+// before it was registered. Deliveries are unique per webhook and event, so
+// retrying an event after a partial failure does not duplicate them.
+func (d *Dispatcher) Enqueue(ctx context.Context) error {
+	filter := bson.M{"type": bson.M{"$in": Events}, QueuedField: bson.M{"$ne": true}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(batchSize)
+	cursor, err := d.Events.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("find events to queue: %w", err)
+	}
+	var list []events.Event
+	if err := cursor.All(ctx, &list); err != nil {
+		return fmt.Errorf("decode events to queue: %w", err)
+	}
+	for _, event := range list {
+		if err := d.Queue(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, event events.Event) error {
+	// Webhooks only receive events that happened after they were created.
+	filter := bson.M{"siteId": event.SiteID, "events": event.Type, "active": true, "createdAt": bson.M{"$lte": event.CreatedAt}}
+	cursor, err := d.Webhooks.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("find webhooks of site %s: %w", event.SiteID.Hex(), err)
+	}
+	var hooks []models.Webhook
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return fmt.Errorf("decode webhooks of site %s: %w", event.SiteID.Hex(), err)
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(newPayload(event))
+	if err != nil {
+		return fmt.Errorf("encode event %s: %w", event.ID.Hex(), err)
+	}
+	now := time.Now().UTC()
+	for _, hook := range hooks {
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			SiteID:        event.SiteID,
+			EventID:       event.ID,
+			Event:         event.Type,
+			Payload:       string(body),
+			Status:        StatusPending,
+			NextAttemptAt: &now,
+			Attempts:      []models.WebhookAttempt{},
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if _, err := d.Deliveries.InsertOne(ctx, delivery); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("queue event %s for webhook %s: %w", event.ID.Hex(), hook.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// DeliverDue sends up to a batch of due deliveries, a few at a time. Each
+// delivery is claimed before it is sent, so concurrent senders never send
+// the same delivery twice.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		budget   = batchSize
+	)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if budget == 0 || firstErr != nil {
+					mu.Unlock()
+					return
+				}
+				budget--
+				mu.Unlock()
+
+				delivery, err := d.claim(ctx)
+				if err == nil {
+					err = d.deliver(ctx, delivery)
+				}
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (d *Dispatcher) claim(ctx context.Context) (models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	var delivery models.WebhookDelivery
+	err := d.Deliveries.FindOneAndUpdate(ctx,
+		bson.M{"status": StatusPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAttemptAt": now.Add(claimTimeout)}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return delivery, fmt.Errorf("claim delivery: %w", err)
+	}
+	return delivery, err
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	var hook models.Webhook
+	err := d.Webhooks.FindOne(ctx, bson.M{"_id": delivery.WebhookID}).Decode(&hook)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("find webhook %s: %w", delivery.WebhookID.Hex(), err)
+	}
+
+	var attempt models.WebhookAttempt
+	switch {
+	case err != nil:
+		attempt = models.WebhookAttempt{At: time.Now().UTC(), Error: "webhook deleted"}
+		delivery.Tries = MaxTries - 1
+	case !hook.Active:
+		attempt = models.WebhookAttempt{At: time.Now().UTC(), Error: "webhook disabled"}
+		delivery.Tries = MaxTries - 1
+	default:
+		attempt = d.send(ctx, hook, delivery)
+	}
+	return d.record(ctx, delivery, attempt)
+}
+
+// send makes one request and reports how it went.
+func (d *Dispatcher) send(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery) models.WebhookAttempt {
+	start := time.Now().UTC()
+	attempt := models.WebhookAttempt{At: start}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.Hex())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, start, []byte(delivery.Payload)))
+
+	resp, err := d.Client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = strings.ToValidUTF8(string(body), "")
+	return attempt
+}
+
+// record stores the outcome of attempt. The outcome only applies while our
+// claim holds; a delivery redelivered in the meantime just gains the log
+// entry.
+func (d *Dispatcher) record(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	next := advance(delivery, attempt, time.Now().UTC())
+	set := bson.M{"status": next.Status, "tries": next.Tries, "updatedAt": next.UpdatedAt}
+	unset := bson.M{}
+	if next.NextAttemptAt != nil {
+		set["nextAttemptAt"] = *next.NextAttemptAt
+	} else {
+		unset["nextAttemptAt"] = ""
+	}
+	if next.DeliveredAt != nil {
+		set["deliveredAt"] = *next.DeliveredAt
+	}
+	push := bson.M{"attempts": bson.M{"$each": []models.WebhookAttempt{attempt}, "$slice": -MaxAttemptLog}}
+
+	update := bson.M{"$set": set, "$push": push}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := d.Deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID, "status": StatusPending, "nextAttemptAt": delivery.NextAttemptAt}, update)
+	if err == nil && result.MatchedCount == 0 {
+		_, err = d.Deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$push": push})
+	}
+	if err != nil {
+		return fmt.Errorf("record delivery %s: %w", delivery.ID.Hex(), err)
+	}
+	return nil
+}
+
+// advance returns delivery after attempt: succeeded on a 2xx answer, failed
+// once it has run out of tries, and otherwise due again after Backoff.
+func advance(delivery models.WebhookDelivery, attempt models.WebhookAttempt, now time.Time) models.WebhookDelivery {
+	delivery.Tries++
+	delivery.UpdatedAt = now
+	delivery.Attempts = append(append([]models.WebhookAttempt{}, delivery.Attempts...), attempt)
+	if len(delivery.Attempts) > MaxAttemptLog {
+		delivery.Attempts = delivery.Attempts[len(delivery.Attempts)-MaxAttemptLog:]
+	}
+	switch {
+	case attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		delivery.Status = StatusSucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Tries >= MaxTries:
+		delivery.Status = StatusFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(Backoff(delivery.Tries))
+		delivery.Status = StatusPending
+		delivery.NextAttemptAt = &next
+	}
+	return delivery
+}
+
+// Requeue makes delivery due at now with a fresh set of tries. Its log and
+// payload are kept.
+func Requeue(delivery *models.WebhookDelivery, now time.Time) {
+	delivery.Status = StatusPending
+	delivery.Tries = 0
+	delivery.NextAttemptAt = &now
+	delivery.DeliveredAt = nil
+	delivery.UpdatedAt = now
+}
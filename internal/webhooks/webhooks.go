@@ -0,0 +1,99 @@
+// Package webhooks delivers site events to URLs registered by site owners.
+// Events are queued as persistent deliveries and sent with HMAC-SHA256
+// signatures, retrying with exponential backoff until the receiver answers
+// with a 2xx status.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	// MaxTries is how many times a delivery is attempted before it fails.
+	MaxTries = 10
+
+	// Retention is how long deliveries are kept.
+	Retention = 30 * 24 * time.Hour
+
+	// MaxAttemptLog is how many attempts a delivery keeps in its log.
+	MaxAttemptLog = 20
+
+	SignatureHeader = "X-Youpp-Signature"
+	TimestampHeader = "X-Youpp-Timestamp"
+	EventHeader     = "X-Youpp-Event"
+	DeliveryHeader  = "X-Youpp-Delivery"
+
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+)
+
+// Events lists the event types a webhook can subscribe to.
+var Events = []string{events.SitePublished, events.SiteUnpublished, events.ContentUpdated, events.MemberAdded}
+
+// ValidEvent reports whether webhooks can subscribe to eventType.
+func ValidEvent(eventType string) bool {
+	for _, e := range Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidURL reports whether raw is an absolute http(s) URL without
+// credentials.
+func ValidURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return false
+	}
+	return (u.Scheme == "https" || u.Scheme == "http") && len(raw) <= 2048
+}
+
+// Sign returns the signature header value for body sent at timestamp: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret. Receivers should
+// compare it in constant time and reject stale timestamps.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait after the given number of failed tries: 30 seconds,
+// doubling up to 6 hours.
+func Backoff(tries int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < tries && wait < maxRetry; i++ {
+		wait *= 2
+	}
+	if wait > maxRetry {
+		wait = maxRetry
+	}
+	return wait
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	SiteID    string                 `json:"siteId"`
+	CreatedAt time.Time              `json:"createdAt"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+func newPayload(event events.Event) Payload {
+	return Payload{ID: event.ID.Hex(), Type: event.Type, SiteID: event.SiteID.Hex(), CreatedAt: event.CreatedAt, Data: event.Data}
+}
@@ -0,0 +1,255 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/events"
+	"github.com/youpp/youpp-website-adminpanel-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const testSecret = "whsec_test"
+
+func testDelivery() (models.Webhook, models.WebhookDelivery) {
+	hook := models.Webhook{ID: primitive.NewObjectID(), Secret: testSecret, Active: true}
+	now := time.Now().UTC()
+	delivery := models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookID:     hook.ID,
+		Event:         "site.published",
+		Payload:       `{"type":"site.published"}`,
+		Status:        StatusPending,
+		NextAttemptAt: &now,
+	}
+	return hook, delivery
+}
+
+// verify checks a request the way a receiver would: the signature must be
+// the HMAC of "<timestamp>.<body>" under the shared secret.
+func verify(r *http.Request, secret string) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.Header.Get(TimestampHeader) + "." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(r.Header.Get(SignatureHeader)))
+}
+
+func TestSignVerifiedByReceiver(t *testing.T) {
+	hook, delivery := testDelivery()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(EventHeader) != delivery.Event || r.Header.Get(DeliveryHeader) != delivery.ID.Hex() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !verify(r, testSecret) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	hook.URL = server.URL
+
+	d := &Dispatcher{Client: NewClient(true)}
+	if attempt := d.send(context.Background(), hook, delivery); attempt.StatusCode != http.StatusNoContent {
+		t.Fatalf("receiver answered %d %q, want 204", attempt.StatusCode, attempt.Error)
+	}
+
+	hook.Secret = "whsec_other"
+	if attempt := d.send(context.Background(), hook, delivery); attempt.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("signature under the wrong secret got %d, want 401", attempt.StatusCode)
+	}
+}
+
+func TestRetriesBackOffUntilFailed(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	hook, delivery := testDelivery()
+	hook.URL = server.URL
+	d := &Dispatcher{Client: NewClient(true)}
+
+	for try := 1; try <= MaxTries; try++ {
+		attempt := d.send(context.Background(), hook, delivery)
+		if attempt.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("try %d: got status %d, want 503", try, attempt.StatusCode)
+		}
+		delivery = advance(delivery, attempt, attempt.At)
+		if delivery.Tries != try {
+			t.Fatalf("try %d: tries = %d", try, delivery.Tries)
+		}
+		if try < MaxTries {
+			if delivery.Status != StatusPending {
+				t.Fatalf("try %d: status = %q, want pending", try, delivery.Status)
+			}
+			if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(attempt.At.Add(Backoff(try))) {
+				t.Fatalf("try %d: next attempt at %v, want %v", try, delivery.NextAttemptAt, attempt.At.Add(Backoff(try)))
+			}
+		}
+	}
+	if delivery.Status != StatusFailed {
+		t.Fatalf("status after %d tries = %q, want failed", MaxTries, delivery.Status)
+	}
+	if delivery.NextAttemptAt != nil {
+		t.Fatalf("failed delivery is still due at %v", delivery.NextAttemptAt)
+	}
+	if int(atomic.LoadInt32(&hits)) != MaxTries {
+		t.Fatalf("receiver got %d requests, want %d", hits, MaxTries)
+	}
+	if len(delivery.Attempts) != min(MaxTries, MaxAttemptLog) {
+		t.Fatalf("kept %d attempts, want %d", len(delivery.Attempts), min(MaxTries, MaxAttemptLog))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		tries int
+		want  time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{60, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.tries); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.tries, got, tt.want)
+		}
+	}
+}
+
+func TestRedeliverResetsTries(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	hook, delivery := testDelivery()
+	hook.URL = server.URL
+	d := &Dispatcher{Client: NewClient(true)}
+
+	for delivery.Status == StatusPending {
+		delivery = advance(delivery, d.send(context.Background(), hook, delivery), time.Now().UTC())
+	}
+	if delivery.Status != StatusFailed || delivery.Tries != MaxTries {
+		t.Fatalf("got %q after %d tries, want failed after %d", delivery.Status, delivery.Tries, MaxTries)
+	}
+
+	now := time.Now().UTC()
+	Requeue(&delivery, now)
+	if delivery.Tries != 0 || delivery.Status != StatusPending {
+		t.Fatalf("requeued delivery is %q with %d tries, want pending with 0", delivery.Status, delivery.Tries)
+	}
+	if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(now) {
+		t.Fatalf("requeued delivery is due at %v, want %v", delivery.NextAttemptAt, now)
+	}
+
+	healthy.Store(true)
+	delivery = advance(delivery, d.send(context.Background(), hook, delivery), time.Now().UTC())
+	if delivery.Status != StatusSucceeded || delivery.Tries != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("got %q after %d tries, want succeeded after 1", delivery.Status, delivery.Tries)
+	}
+}
+
+func TestNewClientRefusesLoopback(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+	hook, delivery := testDelivery()
+	hook.URL = server.URL
+
+	d := &Dispatcher{Client: NewClient(false)}
+	attempt := d.send(context.Background(), hook, delivery)
+	if attempt.StatusCode != 0 || !strings.Contains(attempt.Error, "refusing to connect") {
+		t.Fatalf("got status %d error %q, want a refused connection", attempt.StatusCode, attempt.Error)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Fatal("loopback receiver was reached")
+	}
+	if next := advance(delivery, attempt, attempt.At); next.Status != StatusPending || next.Tries != 1 {
+		t.Fatalf("refused attempt left %q with %d tries, want a pending retry", next.Status, next.Tries)
+	}
+}
+
+func TestQueueOnPublish(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	hook := models.Webhook{ID: primitive.NewObjectID(), Events: []string{events.SitePublished}, Active: true}
+	event := events.Event{ID: primitive.NewObjectID(), SiteID: primitive.NewObjectID(), Type: events.SitePublished, CreatedAt: time.Now().UTC()}
+
+	mt.Run("queues a delivery and marks the event", func(mt *mtest.T) {
+		var hookDoc bson.D
+		raw, err := bson.Marshal(hook)
+		if err == nil {
+			err = bson.Unmarshal(raw, &hookDoc)
+		}
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.webhooks", mtest.FirstBatch, hookDoc),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		d := &Dispatcher{Events: mt.Coll, Webhooks: mt.Coll, Deliveries: mt.Coll}
+		if err := d.Queue(context.Background(), event); err != nil {
+			mt.Fatalf("Queue: %v", err)
+		}
+		var commands []string
+		for started := mt.GetStartedEvent(); started != nil; started = mt.GetStartedEvent() {
+			commands = append(commands, started.CommandName)
+			if started.CommandName == "insert" {
+				doc := started.Command.Lookup("documents").Array().Index(0).Value().Document()
+				if id := doc.Lookup("eventId").ObjectID(); id != event.ID {
+					mt.Fatalf("delivery for event %s, want %s", id.Hex(), event.ID.Hex())
+				}
+			}
+		}
+		if strings.Join(commands, ",") != "find,insert,update" {
+			mt.Fatalf("sent %v, want find, insert and update", commands)
+		}
+	})
+
+	mt.Run("ignores other event types", func(mt *mtest.T) {
+		d := &Dispatcher{Events: mt.Coll, Webhooks: mt.Coll, Deliveries: mt.Coll}
+		other := event
+		other.Type = events.Presence
+		if err := d.Queue(context.Background(), other); err != nil {
+			mt.Fatalf("Queue: %v", err)
+		}
+		if started := mt.GetStartedEvent(); started != nil {
+			mt.Fatalf("sent %s for a presence event", started.CommandName)
+		}
+	})
+}